@@ -0,0 +1,43 @@
+// Command matrix merges the run reports of several conformance runs into a
+// Linkerd version × Kubernetes version compatibility table, printed as Markdown.
+//
+// Usage:
+//
+//	go run ./cmd/matrix <report or directory>...
+//
+// Directories are searched recursively for run reports
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/linkerd/linkerd2-conformance/report"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s <report or directory>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	reports, err := report.Load(flag.Args()...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load the run reports: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(reports) == 0 {
+		fmt.Fprintln(os.Stderr, "no run reports found")
+		os.Exit(1)
+	}
+
+	fmt.Print(report.NewMatrix(reports).Markdown())
+}
@@ -17,12 +17,24 @@ func TestMain(m *testing.M) {
 		version = h.GetVersion()
 	}
 
+	// the binary is installed while holding the node lock, as
+	// all the Ginkgo nodes share it when running in parallel
+	if err := utils.AcquireNodeLock(true); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
 	// install linkerd binary
 	if err := utils.InstallLinkerdBinary(c.GetLinkerdPath(), version, false, true); err != nil {
 		fmt.Printf("error installing linkerd2 (%s): %s", version, err.Error())
 		os.Exit(1)
 	}
 
+	if err := utils.ReleaseNodeLock(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
 	code := m.Run()
 	os.Exit(code)
 }
@@ -3,9 +3,13 @@ module github.com/linkerd/linkerd2-conformance
 go 1.14
 
 require (
+	github.com/golang/protobuf v1.4.2
+	github.com/gorilla/websocket v1.4.0
 	github.com/linkerd/linkerd2 v0.5.1-0.20200629212941-f00c17e52a6f
 	github.com/onsi/ginkgo v1.13.0
 	github.com/onsi/gomega v1.10.1
+	github.com/prometheus/common v0.7.0
 	gopkg.in/yaml.v2 v2.3.0
+	k8s.io/api v0.17.4
 	k8s.io/apimachinery v0.17.4
 )
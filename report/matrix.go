@@ -0,0 +1,183 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Run holds the merged reports of the Ginkgo nodes of a run
+type Run struct {
+	Metadata Metadata
+	Results  Results
+}
+
+// Cell holds the runs of a Linkerd version against a Kubernetes minor version
+type Cell struct {
+	Runs []*Run
+}
+
+// FailedRuns returns the number of runs with failed specs
+func (c *Cell) FailedRuns() int {
+	failed := 0
+	for _, run := range c.Runs {
+		if !run.Results.Succeeded {
+			failed++
+		}
+	}
+	return failed
+}
+
+// String returns the outcome of the runs of the cell
+func (c *Cell) String() string {
+	if c == nil || len(c.Runs) == 0 {
+		return "-"
+	}
+
+	runs := "runs"
+	if len(c.Runs) == 1 {
+		runs = "run"
+	}
+
+	if failed := c.FailedRuns(); failed > 0 {
+		return fmt.Sprintf("FAIL (%d/%d %s)", failed, len(c.Runs), runs)
+	}
+	return fmt.Sprintf("PASS (%d %s)", len(c.Runs), runs)
+}
+
+// Matrix is a Linkerd version × Kubernetes version compatibility table
+type Matrix struct {
+	LinkerdVersions    []string
+	KubernetesVersions []string
+
+	// cells maps Linkerd versions to Kubernetes minor versions to their cell
+	cells map[string]map[string]*Cell
+}
+
+// runKey identifies the reports of the Ginkgo nodes of a run. The versions are part
+// of the key, so that reports of different runs are never merged even if their IDs collide
+type runKey struct {
+	id                string
+	linkerdVersion    string
+	kubernetesVersion string
+}
+
+// mergeRuns merges the reports sharing the same run ID and versions, which
+// are written by each of the Ginkgo nodes when running in parallel. Reports
+// without a run ID are never merged
+func mergeRuns(reports []RunReport) []*Run {
+	runs := []*Run{}
+	byKey := map[runKey]*Run{}
+
+	for _, r := range reports {
+		key := runKey{r.Metadata.RunID, r.Metadata.LinkerdVersion, r.Metadata.KubernetesVersion}
+		if run, ok := byKey[key]; ok && key.id != "" {
+			run.Results.Succeeded = run.Results.Succeeded && r.Results.Succeeded
+			run.Results.Passed += r.Results.Passed
+			run.Results.Failed += r.Results.Failed
+			run.Results.Skipped += r.Results.Skipped
+			run.Results.FailedSpecs = append(run.Results.FailedSpecs, r.Results.FailedSpecs...)
+			continue
+		}
+
+		run := &Run{Metadata: r.Metadata, Results: r.Results}
+		runs = append(runs, run)
+		byKey[key] = run
+	}
+	return runs
+}
+
+// NewMatrix merges the given reports into a compatibility matrix
+func NewMatrix(reports []RunReport) *Matrix {
+	m := &Matrix{cells: map[string]map[string]*Cell{}}
+
+	for _, run := range mergeRuns(reports) {
+		l5d := run.Metadata.LinkerdVersion
+		k8s := run.Metadata.KubernetesMinorVersion()
+
+		if _, ok := m.cells[l5d]; !ok {
+			m.cells[l5d] = map[string]*Cell{}
+			m.LinkerdVersions = append(m.LinkerdVersions, l5d)
+		}
+
+		cell, ok := m.cells[l5d][k8s]
+		if !ok {
+			cell = &Cell{}
+			m.cells[l5d][k8s] = cell
+		}
+		cell.Runs = append(cell.Runs, run)
+
+		if indexOf(m.KubernetesVersions, k8s) < 0 {
+			m.KubernetesVersions = append(m.KubernetesVersions, k8s)
+		}
+	}
+
+	sort.Slice(m.LinkerdVersions, func(i, j int) bool {
+		return compareLinkerdVersions(m.LinkerdVersions[i], m.LinkerdVersions[j])
+	})
+	sort.Slice(m.KubernetesVersions, func(i, j int) bool {
+		return compareKubernetesVersions(m.KubernetesVersions[i], m.KubernetesVersions[j])
+	})
+	return m
+}
+
+func indexOf(arr []string, item string) int {
+	for i, v := range arr {
+		if v == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// Cell returns the cell of the given versions, or nil if
+// there are no runs of the Linkerd version against it
+func (m *Matrix) Cell(linkerdVersion, kubernetesVersion string) *Cell {
+	return m.cells[linkerdVersion][kubernetesVersion]
+}
+
+// Markdown renders the matrix, followed by the details of each run, as Markdown tables
+func (m *Matrix) Markdown() string {
+	var b strings.Builder
+
+	b.WriteString("| Linkerd \\ Kubernetes |")
+	for _, k8s := range m.KubernetesVersions {
+		fmt.Fprintf(&b, " %s |", k8s)
+	}
+	b.WriteString("\n|-|")
+	for range m.KubernetesVersions {
+		b.WriteString("-|")
+	}
+	b.WriteString("\n")
+
+	for _, l5d := range m.LinkerdVersions {
+		fmt.Fprintf(&b, "| %s |", l5d)
+		for _, k8s := range m.KubernetesVersions {
+			fmt.Fprintf(&b, " %s |", m.Cell(l5d, k8s))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n| Linkerd | Kubernetes | Cloud provider | CNI | Nodes | Passed | Failed | Skipped | Failed specs |\n")
+	b.WriteString("|-|-|-|-|-|-|-|-|-|\n")
+	for _, l5d := range m.LinkerdVersions {
+		for _, k8s := range m.KubernetesVersions {
+			cell := m.Cell(l5d, k8s)
+			if cell == nil {
+				continue
+			}
+
+			for _, run := range cell.Runs {
+				cni := run.Metadata.CNI
+				if run.Metadata.LinkerdCNI {
+					cni += " + linkerd-cni"
+				}
+
+				fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %d | %d | %d | %s |\n",
+					l5d, run.Metadata.KubernetesVersion, run.Metadata.CloudProvider, cni, run.Metadata.NodeSummary(),
+					run.Results.Passed, run.Results.Failed, run.Results.Skipped, strings.Join(run.Results.FailedSpecs, "<br>"))
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newReport(runID, l5d, k8s string, passed, failed int, failedSpecs ...string) RunReport {
+	return RunReport{
+		Metadata: Metadata{RunID: runID, LinkerdVersion: l5d, KubernetesVersion: k8s},
+		Results: Results{
+			Succeeded:   failed == 0,
+			Passed:      passed,
+			Failed:      failed,
+			FailedSpecs: failedSpecs,
+		},
+	}
+}
+
+func TestMergeRuns(t *testing.T) {
+	runs := mergeRuns([]RunReport{
+		// the nodes of a parallel run
+		newReport("a", "stable-2.8.1", "v1.18.2", 3, 0),
+		newReport("a", "stable-2.8.1", "v1.18.2", 2, 1, "inject: can inject"),
+
+		// a different run whose ID collides with the one above
+		newReport("a", "stable-2.8.1", "v1.17.5", 4, 0),
+
+		// reports without a run ID are never merged
+		newReport("", "stable-2.8.1", "v1.18.2", 1, 0),
+		newReport("", "stable-2.8.1", "v1.18.2", 1, 0),
+	})
+
+	if len(runs) != 4 {
+		t.Fatalf("Expected 4 runs, got %d", len(runs))
+	}
+
+	expected := Results{Succeeded: false, Passed: 5, Failed: 1, FailedSpecs: []string{"inject: can inject"}}
+	if !reflect.DeepEqual(runs[0].Results, expected) {
+		t.Fatalf("Expected merged results %+v, got %+v", expected, runs[0].Results)
+	}
+
+	if runs[1].Metadata.KubernetesVersion != "v1.17.5" || runs[1].Results.Passed != 4 {
+		t.Fatalf("Expected the run against v1.17.5 not to be merged, got %+v", runs[1])
+	}
+}
+
+func TestNewMatrix(t *testing.T) {
+	m := NewMatrix([]RunReport{
+		newReport("a", "stable-2.10.0", "v1.18.2", 3, 0),
+		newReport("b", "stable-2.8.1", "v1.9.11", 3, 0),
+		newReport("c", "stable-2.8.1", "v1.18.6", 2, 1),
+		newReport("d", "stable-2.8.1", "v1.18.2", 3, 0),
+	})
+
+	expectedLinkerd := []string{"stable-2.8.1", "stable-2.10.0"}
+	if !reflect.DeepEqual(m.LinkerdVersions, expectedLinkerd) {
+		t.Fatalf("Expected Linkerd versions %v, got %v", expectedLinkerd, m.LinkerdVersions)
+	}
+
+	expectedKubernetes := []string{"v1.9", "v1.18"}
+	if !reflect.DeepEqual(m.KubernetesVersions, expectedKubernetes) {
+		t.Fatalf("Expected Kubernetes versions %v, got %v", expectedKubernetes, m.KubernetesVersions)
+	}
+
+	testCases := []struct {
+		l5d, k8s string
+		expected string
+	}{
+		{l5d: "stable-2.8.1", k8s: "v1.9", expected: "PASS (1 run)"},
+		{l5d: "stable-2.8.1", k8s: "v1.18", expected: "FAIL (1/2 runs)"},
+		{l5d: "stable-2.10.0", k8s: "v1.18", expected: "PASS (1 run)"},
+		{l5d: "stable-2.10.0", k8s: "v1.9", expected: "-"},
+	}
+
+	for _, tc := range testCases {
+		if actual := m.Cell(tc.l5d, tc.k8s).String(); actual != tc.expected {
+			t.Errorf("Expected cell %s/%s to be %q, got %q", tc.l5d, tc.k8s, tc.expected, actual)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	passed := newReport("a", "stable-2.8.1", "v1.18.2", 3, 0)
+	passed.Metadata.CloudProvider = "gce"
+	passed.Metadata.CNI = "calico"
+	passed.Metadata.LinkerdCNI = true
+	passed.Metadata.Nodes = []NodeMetadata{
+		{Name: "node-1", OSImage: "Ubuntu 18.04", KernelVersion: "5.4.0", ContainerRuntime: "docker://19.3.8"},
+		{Name: "node-2", OSImage: "Ubuntu 18.04", KernelVersion: "5.4.0", ContainerRuntime: "docker://19.3.8"},
+	}
+
+	failed := newReport("b", "stable-2.10.0", "v1.17.5", 1, 2, "inject: can inject", "egress: can send requests")
+	failed.Metadata.CloudProvider = "aws"
+	failed.Metadata.CNI = "aws-vpc-cni"
+
+	expected := `| Linkerd \ Kubernetes | v1.17 | v1.18 |
+|-|-|-|
+| stable-2.8.1 | - | PASS (1 run) |
+| stable-2.10.0 | FAIL (1/1 run) | - |
+
+| Linkerd | Kubernetes | Cloud provider | CNI | Nodes | Passed | Failed | Skipped | Failed specs |
+|-|-|-|-|-|-|-|-|-|
+| stable-2.8.1 | v1.18.2 | gce | calico + linkerd-cni | Ubuntu 18.04 (kernel 5.4.0), docker://19.3.8 | 3 | 0 | 0 |  |
+| stable-2.10.0 | v1.17.5 | aws | aws-vpc-cni | unknown | 1 | 2 | 0 | inject: can inject<br>egress: can send requests |
+`
+
+	if actual := NewMatrix([]RunReport{failed, passed}).Markdown(); actual != expected {
+		t.Fatalf("Expected Markdown:\n%s\ngot:\n%s", expected, actual)
+	}
+}
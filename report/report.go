@@ -0,0 +1,246 @@
+// Package report holds the metadata and results of conformance runs,
+// and merges the reports of several runs into a compatibility matrix.
+// It does not depend on the test configuration, so that reports can be
+// processed outside of a test run
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// kubernetesVersionRegexp matches the major and minor parts of versions such as "v1.18.2-gke.1"
+	kubernetesVersionRegexp = regexp.MustCompile(`^v?(\d+)\.(\d+)`)
+
+	// linkerdVersionRegexp matches the channel and the numeric parts of versions such as "stable-2.8.1"
+	linkerdVersionRegexp = regexp.MustCompile(`^([a-z]+)-(\d+(?:\.\d+)*)$`)
+)
+
+// NodeMetadata holds the system info of a node of the cluster
+type NodeMetadata struct {
+	Name             string `json:"name"`
+	OSImage          string `json:"osImage"`
+	KernelVersion    string `json:"kernelVersion"`
+	ContainerRuntime string `json:"containerRuntime"`
+	KubeletVersion   string `json:"kubeletVersion"`
+	Architecture     string `json:"architecture"`
+}
+
+// Metadata describes the cluster and the control plane a run was performed against
+type Metadata struct {
+	// RunID is unique to each run, and is shared by the
+	// reports of the Ginkgo nodes of a parallel run
+	RunID             string         `json:"runID"`
+	StartedAt         time.Time      `json:"startedAt"`
+	LinkerdVersion    string         `json:"linkerdVersion"`
+	KubernetesVersion string         `json:"kubernetesVersion"`
+	FeatureGates      string         `json:"featureGates,omitempty"` // empty if the API server flags cannot be read
+	CloudProvider     string         `json:"cloudProvider"`
+	CNI               string         `json:"cni"`
+	LinkerdCNI        bool           `json:"linkerdCNI"` // the linkerd-cni plugin is used instead of proxy-init
+	HA                bool           `json:"ha"`
+	Nodes             []NodeMetadata `json:"nodes"`
+}
+
+// Results holds the outcome of the specs of a run
+type Results struct {
+	Succeeded   bool     `json:"succeeded"`
+	Passed      int      `json:"passed"`
+	Failed      int      `json:"failed"`
+	Skipped     int      `json:"skipped"`
+	FailedSpecs []string `json:"failedSpecs,omitempty"`
+	RunTime     string   `json:"runTime"`
+}
+
+// RunReport is the report of a single run
+type RunReport struct {
+	Metadata Metadata `json:"metadata"`
+	Results  Results  `json:"results"`
+}
+
+func distinct(values []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// NodeSummary returns the distinct OS images, kernels and
+// container runtimes of the nodes, as a single line
+func (m Metadata) NodeSummary() string {
+	if len(m.Nodes) == 0 {
+		return "unknown"
+	}
+
+	var os, kernels, runtimes []string
+	for _, node := range m.Nodes {
+		os = append(os, node.OSImage)
+		kernels = append(kernels, node.KernelVersion)
+		runtimes = append(runtimes, node.ContainerRuntime)
+	}
+
+	return fmt.Sprintf("%s (kernel %s), %s",
+		strings.Join(distinct(os), ", "),
+		strings.Join(distinct(kernels), ", "),
+		strings.Join(distinct(runtimes), ", "))
+}
+
+// String returns a summary of the metadata, printed before the specs run
+func (m Metadata) String() string {
+	featureGates := m.FeatureGates
+	if featureGates == "" {
+		featureGates = "unknown"
+	}
+
+	return fmt.Sprintf(`Run metadata:
+  Linkerd version: %s
+  Kubernetes version: %s
+  Feature gates: %s
+  Cloud provider: %s
+  CNI: %s
+  linkerd-cni: %v
+  Nodes: %d - %s
+`, m.LinkerdVersion, m.KubernetesVersion, featureGates, m.CloudProvider, m.CNI, m.LinkerdCNI, len(m.Nodes), m.NodeSummary())
+}
+
+// KubernetesMinorVersion returns the major and minor parts of
+// the Kubernetes version, such as "v1.18" for "v1.18.2-gke.1"
+func (m Metadata) KubernetesMinorVersion() string {
+	match := kubernetesVersionRegexp.FindStringSubmatch(m.KubernetesVersion)
+	if match == nil {
+		return m.KubernetesVersion
+	}
+	return fmt.Sprintf("v%s.%s", match[1], match[2])
+}
+
+// compareKubernetesVersions orders versions such as "v1.9" before "v1.18"
+func compareKubernetesVersions(a, b string) bool {
+	ma := kubernetesVersionRegexp.FindStringSubmatch(a)
+	mb := kubernetesVersionRegexp.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		return a < b
+	}
+
+	for i := 1; i <= 2; i++ {
+		na, _ := strconv.Atoi(ma[i])
+		nb, _ := strconv.Atoi(mb[i])
+		if na != nb {
+			return na < nb
+		}
+	}
+	return a < b
+}
+
+// compareLinkerdVersions orders versions by release channel, and then such
+// that "stable-2.8.1" comes before "stable-2.10.0". Versions which do not
+// belong to a release channel (such as "git-abcdef") are ordered last
+func compareLinkerdVersions(a, b string) bool {
+	ma := linkerdVersionRegexp.FindStringSubmatch(a)
+	mb := linkerdVersionRegexp.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		if ma == nil && mb == nil {
+			return a < b
+		}
+		return mb == nil
+	}
+
+	if ma[1] != mb[1] {
+		return ma[1] < mb[1]
+	}
+
+	pa := strings.Split(ma[2], ".")
+	pb := strings.Split(mb[2], ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, _ := strconv.Atoi(pa[i])
+		nb, _ := strconv.Atoi(pb[i])
+		if na != nb {
+			return na < nb
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+// Write writes the report to the given path as JSON
+func Write(path string, r RunReport) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %s", path, err)
+	}
+
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
+
+func read(path string) (RunReport, error) {
+	var r RunReport
+
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+
+	if err := json.Unmarshal(in, &r); err != nil {
+		return r, fmt.Errorf("failed to parse %s: %s", path, err)
+	}
+
+	if r.Metadata.LinkerdVersion == "" || r.Metadata.KubernetesVersion == "" {
+		return r, fmt.Errorf("%s is not a run report", path)
+	}
+	return r, nil
+}
+
+// Load reads the reports at the given paths. Directories are walked
+// recursively, and the JSON files in them which are not run reports are ignored
+func Load(paths ...string) ([]RunReport, error) {
+	reports := []RunReport{}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			r, err := read(path)
+			if err != nil {
+				return nil, err
+			}
+			reports = append(reports, r)
+			continue
+		}
+
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() || filepath.Ext(p) != ".json" {
+				return nil
+			}
+
+			if r, err := read(p); err == nil {
+				reports = append(reports, r)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reports, nil
+}
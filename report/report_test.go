@@ -0,0 +1,93 @@
+package report
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestKubernetesMinorVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected string
+	}{
+		{version: "v1.18.2", expected: "v1.18"},
+		{version: "v1.18.2-gke.1", expected: "v1.18"},
+		{version: "1.9.0", expected: "v1.9"},
+		{version: "v1.17.9-eks-4c6976", expected: "v1.17"},
+		{version: "unknown", expected: "unknown"},
+	}
+
+	for _, tc := range testCases {
+		m := Metadata{KubernetesVersion: tc.version}
+		if actual := m.KubernetesMinorVersion(); actual != tc.expected {
+			t.Errorf("Expected minor version of %s to be %s, got %s", tc.version, tc.expected, actual)
+		}
+	}
+}
+
+func TestCompareKubernetesVersions(t *testing.T) {
+	versions := []string{"v1.18", "unknown", "v1.9", "v2.0", "v1.16"}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareKubernetesVersions(versions[i], versions[j])
+	})
+
+	expected := []string{"unknown", "v1.9", "v1.16", "v1.18", "v2.0"}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Fatalf("Expected versions to be sorted as %v, got %v", expected, versions)
+	}
+}
+
+func TestCompareLinkerdVersions(t *testing.T) {
+	versions := []string{"stable-2.10.0", "git-abcdef", "edge-20.6.4", "stable-2.8.1", "stable-2.9.0", "edge-20.10.1", "stable-2.8"}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareLinkerdVersions(versions[i], versions[j])
+	})
+
+	expected := []string{"edge-20.6.4", "edge-20.10.1", "stable-2.8", "stable-2.8.1", "stable-2.9.0", "stable-2.10.0", "git-abcdef"}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Fatalf("Expected versions to be sorted as %v, got %v", expected, versions)
+	}
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := RunReport{
+		Metadata: Metadata{RunID: "run", LinkerdVersion: "stable-2.8.1", KubernetesVersion: "v1.18.2"},
+		Results:  Results{Succeeded: true, Passed: 3},
+	}
+
+	if err := Write(filepath.Join(dir, "nested", "report.json"), r); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// JSON files which are not run reports are ignored
+	if err := ioutil.WriteFile(filepath.Join(dir, "other.json"), []byte(`{"foo": "bar"}`), 0644); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	reports, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(reports))
+	}
+
+	if !reflect.DeepEqual(reports[0], r) {
+		t.Fatalf("Expected report %+v, got %+v", r, reports[0])
+	}
+
+	if _, err := Load(filepath.Join(dir, "other.json")); err == nil {
+		t.Fatal("Expected an error when loading a file which is not a run report")
+	}
+}
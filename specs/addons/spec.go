@@ -0,0 +1,24 @@
+package addons
+
+import (
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+)
+
+// RunAddOnTests runs the specs for the add-ons of the control plane
+func RunAddOnTests() bool {
+	return ginkgo.Describe("add-ons: ", func() {
+		_, c := utils.GetHelperAndConfig()
+
+		_ = utils.ShouldTestSkip(c.SkipAddOns(), "Skipping add-on tests")
+		utils.RequireControlPlane(false)
+
+		ginkgo.It("can scrape proxy metrics with Prometheus", testPrometheus)
+
+		ginkgo.It("can use an external Prometheus instead of the bundled one", testExternalPrometheus)
+
+		ginkgo.It("can serve Grafana dashboards through linkerd-web", testGrafana)
+
+		ginkgo.It("can collect spans from an instrumented app with the tracing add-on", testTracing)
+	})
+}
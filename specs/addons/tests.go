@@ -0,0 +1,321 @@
+package addons
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+const (
+	grafanaDeploy = "linkerd-grafana"
+
+	jaegerPort          = 16686
+	collectorPort       = 55678
+	collectorSvcAccount = "linkerd-collector"
+	proxyTraceService   = "linkerd-proxy"
+)
+
+var (
+	// dashboards are the UIDs of some of the dashboards shipped with Grafana
+	dashboards = []string{
+		"linkerd-top-line",
+		"linkerd-health",
+		"linkerd-namespace",
+		"linkerd-pod",
+		"linkerd-service",
+	}
+)
+
+// emojivotoNs returns the namespace in which emojivoto is installed for the add-on tests
+func emojivotoNs() string {
+	return utils.GetSpecNamespace("addons-emojivoto")
+}
+
+// tracingNs returns the namespace in which emojivoto is installed with tracing enabled
+func tracingNs() string {
+	return utils.GetSpecNamespace("addons-tracing")
+}
+
+// prometheusResponse is used for unmarshalling the response of the Prometheus query API
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Result []struct {
+			Metric map[string]string `json:"metric"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// prometheusTargets is used for unmarshalling the response of the Prometheus targets API
+type prometheusTargets struct {
+	Status string `json:"status"`
+	Data   struct {
+		ActiveTargets []struct {
+			Labels map[string]string `json:"labels"`
+			Health string            `json:"health"`
+		} `json:"activeTargets"`
+	} `json:"data"`
+}
+
+// grafanaDashboard is used for unmarshalling the response of the Grafana dashboard API
+type grafanaDashboard struct {
+	Dashboard struct {
+		UID   string `json:"uid"`
+		Title string `json:"title"`
+	} `json:"dashboard"`
+}
+
+// jaegerServices is used for unmarshalling the response of the Jaeger services API
+type jaegerServices struct {
+	Data []string `json:"data"`
+}
+
+func checkAddOnDeploys(deploys ...string) {
+	h, _ := utils.GetHelperAndConfig()
+
+	for _, deploy := range deploys {
+		ginkgo.By(fmt.Sprintf("Checking deploy/%s", deploy))
+		err := h.CheckPods(h.GetLinkerdNamespace(), deploy, 1)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to validate pods of deploy/%s: %s", deploy, utils.Err(err)))
+
+		err = h.CheckDeployment(h.GetLinkerdNamespace(), deploy, 1)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to validate deploy/%s: %s", deploy, utils.Err(err)))
+	}
+}
+
+func getControlPlaneURL(deploy string, port int) string {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Port-forwarding to deploy/%s", deploy))
+	u, err := h.URLFor(h.GetLinkerdNamespace(), deploy, port)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to port-forward to deploy/%s: %s", deploy, utils.Err(err)))
+	return u
+}
+
+// getPrometheusURL port-forwards to the Prometheus used by the control plane,
+// which is either the bundled one or the one installed by the tests
+func getPrometheusURL() string {
+	h, c := utils.GetHelperAndConfig()
+
+	if !c.ExternalPrometheus() {
+		checkAddOnDeploys(utils.PrometheusDeploy)
+		return getControlPlaneURL(utils.PrometheusDeploy, utils.PrometheusPort)
+	}
+
+	ns := c.GetExternalPrometheusNamespace()
+	ginkgo.By(fmt.Sprintf("Port-forwarding to deploy/%s in namespace %s", utils.ExternalPrometheusDeploy, ns))
+	u, err := h.URLFor(ns, utils.ExternalPrometheusDeploy, utils.PrometheusPort)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to port-forward to the external Prometheus: %s", utils.Err(err)))
+	return u
+}
+
+func testPrometheus() {
+	h, c := utils.GetHelperAndConfig()
+
+	if !c.IsComponentEnabled(utils.PrometheusDeploy) && !c.ExternalPrometheus() {
+		ginkgo.Skip("Skipping Prometheus tests as the add-on is disabled")
+	}
+
+	utils.EnsureEmojivoto(emojivotoNs())
+	promURL := getPrometheusURL()
+	query := fmt.Sprintf("request_total{namespace=%q, direction=\"inbound\"}", emojivotoNs())
+
+	ginkgo.By("Checking that Prometheus has scraped the metrics of the emojivoto proxies")
+	err := h.RetryFor(2*time.Minute, func() error {
+		body, err := h.HTTPGetURL(fmt.Sprintf("%s/api/v1/query?query=%s", promURL, url.QueryEscape(query)))
+		if err != nil {
+			return err
+		}
+
+		var resp prometheusResponse
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			return fmt.Errorf("failed to parse Prometheus response: %s", err)
+		}
+
+		if resp.Status != "success" {
+			return fmt.Errorf("query %s failed: %s", query, body)
+		}
+
+		scraped := map[string]bool{}
+		for _, r := range resp.Data.Result {
+			scraped[r.Metric["deployment"]] = true
+		}
+
+		for _, deploy := range utils.EmojivotoDeploys {
+			if !scraped[deploy] {
+				return fmt.Errorf("no inbound request metrics found for deploy/%s", deploy)
+			}
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func testGrafana() {
+	h, c := utils.GetHelperAndConfig()
+
+	if !c.IsAddOnEnabled(utils.Grafana) {
+		ginkgo.Skip("Skipping Grafana tests as the add-on is disabled")
+	}
+	checkAddOnDeploys(grafanaDeploy)
+
+	// Grafana is served by linkerd-web under `/grafana`
+	webURL := getControlPlaneURL(utils.WebDeploy, utils.WebPort)
+
+	ginkgo.By("Checking Grafana health through linkerd-web")
+	_, err := h.HTTPGetURL(webURL + "/grafana/api/health")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("Grafana is not healthy: %s", utils.Err(err)))
+
+	for _, uid := range dashboards {
+		ginkgo.By(fmt.Sprintf("Loading dashboard %s through linkerd-web", uid))
+		body, err := h.HTTPGetURL(fmt.Sprintf("%s/grafana/api/dashboards/uid/%s", webURL, uid))
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to load dashboard %s: %s", uid, utils.Err(err)))
+
+		var d grafanaDashboard
+		err = json.Unmarshal([]byte(body), &d)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to parse dashboard %s: %s", uid, utils.Err(err)))
+		gomega.Expect(d.Dashboard.UID).Should(gomega.Equal(uid))
+		gomega.Expect(d.Dashboard.Title).ShouldNot(gomega.BeEmpty(), fmt.Sprintf("dashboard %s has no title", uid))
+	}
+}
+
+func testTracing() {
+	h, c := utils.GetHelperAndConfig()
+
+	if !c.IsAddOnEnabled(utils.Tracing) {
+		ginkgo.Skip("Skipping tracing tests as the add-on is disabled")
+	}
+	checkAddOnDeploys(utils.CollectorDeploy, utils.JaegerDeploy)
+
+	collector := fmt.Sprintf("%s.%s.svc.%s:%d", utils.CollectorDeploy, h.GetLinkerdNamespace(), h.GetClusterDomain(), collectorPort)
+	utils.TestTracedEmojivoto(tracingNs(), collector, collectorSvcAccount)
+
+	jaegerURL := getControlPlaneURL(utils.JaegerDeploy, jaegerPort)
+
+	// vote-bot continuously sends requests to emojivoto, whose
+	// spans are sent to Jaeger through the collector
+	ginkgo.By("Checking that Jaeger has received spans from emojivoto and its proxies")
+	err := h.RetryFor(3*time.Minute, func() error {
+		body, err := h.HTTPGetURL(jaegerURL + "/api/services")
+		if err != nil {
+			return err
+		}
+
+		var resp jaegerServices
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			return fmt.Errorf("failed to parse Jaeger response: %s", err)
+		}
+
+		appSpans, proxySpans := false, false
+		for _, svc := range resp.Data {
+			switch {
+			case svc == proxyTraceService:
+				proxySpans = true
+			case !strings.HasPrefix(svc, "jaeger"):
+				appSpans = true
+			}
+		}
+
+		if !proxySpans {
+			return fmt.Errorf("no spans received from the proxies (services: %v)", resp.Data)
+		}
+
+		if !appSpans {
+			return fmt.Errorf("no spans received from emojivoto (services: %v)", resp.Data)
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func testExternalPrometheus() {
+	h, c := utils.GetHelperAndConfig()
+
+	if !c.ExternalPrometheus() {
+		ginkgo.Skip("Skipping external Prometheus tests as `controlPlane.config.externalPrometheus` is not set")
+	}
+
+	ginkgo.By(fmt.Sprintf("Checking that deploy/%s is not installed", utils.PrometheusDeploy))
+	out, err := h.Kubectl("", "-n", h.GetLinkerdNamespace(), "get", "deploy", "-l", "linkerd.io/control-plane-component=prometheus", "-o", "name")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get deployments: %s: %s", utils.Err(err), out))
+	gomega.Expect(strings.TrimSpace(out)).Should(gomega.BeEmpty(), "the bundled Prometheus must not be installed")
+
+	utils.EnsureEmojivoto(emojivotoNs())
+	promURL := getPrometheusURL()
+
+	ginkgo.By("Checking that the external Prometheus scrapes the control plane and the emojivoto proxies")
+	err = h.RetryFor(2*time.Minute, func() error {
+		body, err := h.HTTPGetURL(promURL + "/api/v1/targets")
+		if err != nil {
+			return err
+		}
+
+		var resp prometheusTargets
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			return fmt.Errorf("failed to parse Prometheus response: %s", err)
+		}
+
+		controller, proxies := false, false
+		for _, t := range resp.Data.ActiveTargets {
+			if t.Health != "up" {
+				continue
+			}
+
+			switch {
+			case t.Labels["job"] == "linkerd-controller":
+				controller = true
+			case t.Labels["job"] == "linkerd-proxy" && t.Labels["namespace"] == emojivotoNs():
+				proxies = true
+			}
+		}
+
+		if !controller {
+			return errors.New("no healthy linkerd-controller targets found")
+		}
+
+		if !proxies {
+			return fmt.Errorf("no healthy linkerd-proxy targets found in namespace %s", emojivotoNs())
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By("Checking that `linkerd stat` reads metrics from the external Prometheus")
+	err = h.RetryFor(2*time.Minute, func() error {
+		out, stderr, err := h.LinkerdRun("stat", "deploy", "-n", emojivotoNs())
+		if err != nil {
+			return fmt.Errorf("`linkerd stat` failed: %s: %s", err, stderr)
+		}
+
+		if !strings.Contains(out, "web") {
+			return fmt.Errorf("expected stats for deploy/web, got:\n%s", out)
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	webURL := getControlPlaneURL(utils.WebDeploy, utils.WebPort)
+
+	ginkgo.By("Checking that the dashboard reads metrics from the external Prometheus")
+	err = h.RetryFor(2*time.Minute, func() error {
+		body, err := h.HTTPGetURL(fmt.Sprintf("%s/api/tps-reports?resource_type=deployment&namespace=%s", webURL, emojivotoNs()))
+		if err != nil {
+			return err
+		}
+
+		if !strings.Contains(body, `"web"`) {
+			return fmt.Errorf("expected stats for deploy/web, got: %s", body)
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	utils.RunCheck(h, false)
+}
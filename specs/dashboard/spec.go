@@ -0,0 +1,26 @@
+package dashboard
+
+import (
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+)
+
+// RunDashboardTests runs the specs for the Linkerd dashboard
+func RunDashboardTests() bool {
+	return ginkgo.Describe("dashboard: ", func() {
+		_, c := utils.GetHelperAndConfig()
+
+		_ = utils.ShouldTestSkip(c.SkipDashboard(), "Skipping dashboard tests")
+		utils.RequireControlPlane(false)
+
+		ginkgo.It("can serve the dashboard and report the control plane version", testVersion)
+
+		ginkgo.It("can list the meshed pods and services of a namespace", testListings)
+
+		ginkgo.It("can report the stats of namespaces and deployments", testStats)
+
+		ginkgo.It("can tap a deployment through a websocket", testTap)
+
+		ginkgo.It("can reject requests with an unexpected Host header", testEnforcedHost)
+	})
+}
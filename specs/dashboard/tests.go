@@ -0,0 +1,290 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/linkerd/linkerd2/controller/api/util"
+	pb "github.com/linkerd/linkerd2/controller/gen/public"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+const (
+	// tappedDeploy is the emojivoto deployment tapped through the dashboard
+	tappedDeploy = "web"
+
+	// unexpectedHost is a Host header which the
+	// dashboard must reject (see `-enforced-host`)
+	unexpectedHost = "conformance.example.com"
+)
+
+var (
+	// emojivotoSvcs are the services of emojivoto
+	emojivotoSvcs = []string{"emoji-svc", "voting-svc", "web-svc"}
+)
+
+// emojivotoNs returns the namespace in which emojivoto is installed for the dashboard tests
+func emojivotoNs() string {
+	return utils.GetSpecNamespace("dashboard-emojivoto")
+}
+
+// versionResponse is used for unmarshalling the response of `/api/version`
+type versionResponse struct {
+	Version struct {
+		ReleaseVersion string `json:"releaseVersion"`
+	} `json:"version"`
+}
+
+func getWebURL() string {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Port-forwarding to deploy/%s", utils.WebDeploy))
+	u, err := h.URLFor(h.GetLinkerdNamespace(), utils.WebDeploy, utils.WebPort)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to port-forward to deploy/%s: %s", utils.WebDeploy, utils.Err(err)))
+	return u
+}
+
+// getAPI requests the given dashboard API endpoint
+// and unmarshals its protobuf JSON response into msg
+func getAPI(webURL, endpoint string, msg proto.Message) error {
+	h, _ := utils.GetHelperAndConfig()
+
+	body, err := h.HTTPGetURL(webURL + endpoint)
+	if err != nil {
+		return err
+	}
+
+	if err := jsonpb.UnmarshalString(body, msg); err != nil {
+		return fmt.Errorf("failed to parse response of %s: %s", endpoint, err)
+	}
+	return nil
+}
+
+// getStatRows returns the rows of the stat tables returned by `/api/tps-reports`
+func getStatRows(webURL, query string) ([]*pb.StatTable_PodGroup_Row, error) {
+	var resp pb.StatSummaryResponse
+	if err := getAPI(webURL, "/api/tps-reports?"+query, &resp); err != nil {
+		return nil, err
+	}
+
+	if e := resp.GetError(); e != nil {
+		return nil, fmt.Errorf("failed to get stats: %s", e.GetError())
+	}
+
+	rows := []*pb.StatTable_PodGroup_Row{}
+	for _, table := range resp.GetOk().GetStatTables() {
+		rows = append(rows, table.GetPodGroup().GetRows()...)
+	}
+	return rows, nil
+}
+
+func testVersion() {
+	h, _ := utils.GetHelperAndConfig()
+	webURL := getWebURL()
+
+	ginkgo.By("Loading the dashboard")
+	body, err := h.HTTPGetURL(webURL)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to load the dashboard: %s", utils.Err(err)))
+	gomega.Expect(body).Should(gomega.ContainSubstring("<html"), "the dashboard did not serve an HTML page")
+
+	ginkgo.By("Checking the version reported by the dashboard")
+	body, err = h.HTTPGetURL(webURL + "/api/version")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get the version: %s", utils.Err(err)))
+
+	var resp versionResponse
+	err = json.Unmarshal([]byte(body), &resp)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to parse the version: %s", utils.Err(err)))
+	gomega.Expect(resp.Version.ReleaseVersion).Should(gomega.Equal(h.GetVersion()), "unexpected control plane version")
+}
+
+func testListings() {
+	h, _ := utils.GetHelperAndConfig()
+
+	utils.EnsureEmojivoto(emojivotoNs())
+	webURL := getWebURL()
+
+	ginkgo.By(fmt.Sprintf("Checking the pods listed for namespace %s", emojivotoNs()))
+	err := h.RetryFor(time.Minute, func() error {
+		var resp pb.ListPodsResponse
+		if err := getAPI(webURL, "/api/pods?namespace="+emojivotoNs(), &resp); err != nil {
+			return err
+		}
+
+		// emojivoto also runs vote-bot, hence there are more pods than deploys
+		if len(resp.GetPods()) < len(utils.EmojivotoDeploys) {
+			return fmt.Errorf("expected at least %d pods, got %d", len(utils.EmojivotoDeploys), len(resp.GetPods()))
+		}
+
+		for _, pod := range resp.GetPods() {
+			if !pod.GetAdded() || !pod.GetProxyReady() {
+				return fmt.Errorf("pod %s is not meshed or its proxy is not ready", pod.GetName())
+			}
+
+			if pod.GetControllerNamespace() != h.GetLinkerdNamespace() {
+				return fmt.Errorf("expected pod %s to report to namespace %s, got %s", pod.GetName(), h.GetLinkerdNamespace(), pod.GetControllerNamespace())
+			}
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Checking the services listed for namespace %s", emojivotoNs()))
+	var resp pb.ListServicesResponse
+	err = getAPI(webURL, "/api/services?namespace="+emojivotoNs(), &resp)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	listed := map[string]bool{}
+	for _, svc := range resp.GetServices() {
+		listed[svc.GetName()] = true
+	}
+
+	for _, svc := range emojivotoSvcs {
+		gomega.Expect(listed[svc]).Should(gomega.BeTrue(), fmt.Sprintf("service %s is not listed", svc))
+	}
+}
+
+func testStats() {
+	h, _ := utils.GetHelperAndConfig()
+
+	utils.EnsureEmojivoto(emojivotoNs())
+	webURL := getWebURL()
+
+	ginkgo.By("Checking the namespaces listed by the dashboard")
+	err := h.RetryFor(2*time.Minute, func() error {
+		rows, err := getStatRows(webURL, "resource_type=namespace&all_namespaces=true")
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if row.GetResource().GetName() != emojivotoNs() {
+				continue
+			}
+
+			if row.GetMeshedPodCount() == 0 || row.GetMeshedPodCount() != row.GetRunningPodCount() {
+				return fmt.Errorf("expected all the pods of namespace %s to be meshed, got %d/%d", emojivotoNs(), row.GetMeshedPodCount(), row.GetRunningPodCount())
+			}
+			return nil
+		}
+		return fmt.Errorf("namespace %s is not listed", emojivotoNs())
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Checking the stats of the deployments in namespace %s", emojivotoNs()))
+	err = h.RetryFor(2*time.Minute, func() error {
+		// the dashboard caches stats by query, hence each
+		// attempt uses a distinct (and ignored) parameter
+		rows, err := getStatRows(webURL, fmt.Sprintf("resource_type=deployment&namespace=%s&attempt=%d", emojivotoNs(), time.Now().UnixNano()))
+		if err != nil {
+			return err
+		}
+
+		withTraffic := map[string]bool{}
+		for _, row := range rows {
+			if row.GetStats().GetSuccessCount() > 0 {
+				withTraffic[row.GetResource().GetName()] = true
+			}
+		}
+
+		for _, deploy := range utils.EmojivotoDeploys {
+			if !withTraffic[deploy] {
+				return fmt.Errorf("no successful requests reported for deploy/%s", deploy)
+			}
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func testTap() {
+	utils.EnsureEmojivoto(emojivotoNs())
+	tapURL := strings.Replace(getWebURL(), "http://", "ws://", 1) + "/api/tap"
+
+	ginkgo.By(fmt.Sprintf("Opening a websocket to %s", tapURL))
+	ws, _, err := websocket.DefaultDialer.Dial(tapURL, nil)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to open a websocket: %s", utils.Err(err)))
+	defer ws.Close()
+
+	params, err := json.Marshal(util.TapRequestParams{
+		Resource:  "deployment/" + tappedDeploy,
+		Namespace: emojivotoNs(),
+		MaxRps:    10,
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Tapping deploy/%s", tappedDeploy))
+	err = ws.WriteMessage(websocket.TextMessage, params)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to send the tap request: %s", utils.Err(err)))
+
+	// vote-bot continuously sends requests to web
+	err = ws.SetReadDeadline(time.Now().Add(time.Minute))
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("no tap events received: %s", utils.Err(err)))
+
+		var event pb.TapEvent
+		err = jsonpb.UnmarshalString(string(msg), &event)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to parse tap event %s: %s", msg, utils.Err(err)))
+
+		if event.GetHttp() == nil {
+			continue
+		}
+
+		labels := event.GetSourceMeta().GetLabels()
+		if event.GetProxyDirection() == pb.TapEvent_INBOUND {
+			labels = event.GetDestinationMeta().GetLabels()
+		}
+		gomega.Expect(labels["deployment"]).Should(gomega.Equal(tappedDeploy), fmt.Sprintf("tap event %s is not from deploy/%s", msg, tappedDeploy))
+		break
+	}
+
+	err = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to close the websocket: %s", utils.Err(err)))
+}
+
+// getWithHost requests the given URL with the given Host header
+func getWithHost(url, host string) (int, string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Host = host
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), err
+}
+
+func testEnforcedHost() {
+	webURL := getWebURL()
+
+	ginkgo.By("Checking that requests with an expected Host header are served")
+	status, body, err := getWithHost(webURL+"/api/version", "localhost")
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+	gomega.Expect(status).Should(gomega.Equal(http.StatusOK), fmt.Sprintf("unexpected response: %s", body))
+
+	ginkgo.By(fmt.Sprintf("Checking that requests with Host header %s are rejected", unexpectedHost))
+	for _, endpoint := range []string{"/", "/api/version", "/api/tps-reports"} {
+		status, body, err := getWithHost(webURL+endpoint, unexpectedHost)
+		gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+		gomega.Expect(status).Should(gomega.Equal(http.StatusBadRequest), fmt.Sprintf("request to %s was not rejected: %s", endpoint, body))
+		gomega.Expect(body).Should(gomega.ContainSubstring("denied"), fmt.Sprintf("unexpected response from %s", endpoint))
+	}
+}
@@ -0,0 +1,35 @@
+package dataplane
+
+import (
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+)
+
+// RunDataplaneTests runs the specs for data plane health checks
+func RunDataplaneTests() bool {
+	return ginkgo.Describe("data plane health: ", func() {
+		_, c := utils.GetHelperAndConfig()
+
+		_ = utils.ShouldTestSkip(c.SkipDataplane(), "Skipping data plane health tests")
+
+		// scaling down the destination controller affects all the meshed workloads
+		utils.RequireControlPlane(true)
+
+		ginkgo.It("can install and inject emojivoto app", func() {
+			utils.TestEmojivotoApp(emojivotoNs())
+			utils.TestEmojivotoInject(emojivotoNs())
+		})
+
+		ginkgo.It("can serve `/ready`, `/live` and `/metrics` on the proxy admin port", testAdminEndpoints)
+
+		ginkgo.It("can gate pod readiness on proxy readiness", testProxyReadinessGatesPod)
+
+		ginkgo.It("can remain ready while the destination controller is unavailable", testReadinessWithoutDestination)
+
+		ginkgo.It("can pass `linkerd check --proxy`", testProxyCheck)
+
+		ginkgo.It("can uninstall emojivoto app", func() {
+			utils.TestEmojivotoUninstall(emojivotoNs())
+		})
+	})
+}
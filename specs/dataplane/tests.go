@@ -0,0 +1,314 @@
+package dataplane
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/testutil"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// emojivotoNs returns the namespace in which emojivoto is installed for the data plane tests
+func emojivotoNs() string {
+	return utils.GetSpecNamespace("dataplane-emojivoto")
+}
+
+// readinessNs returns the namespace in which the workload held unready is created
+func readinessNs() string {
+	return utils.GetSpecNamespace("dataplane-readiness")
+}
+
+const (
+	destinationDeploy = "linkerd-destination"
+	identityDeploy    = "linkerd-identity"
+
+	workloadManifest = "testdata/inject/inject_test.yaml"
+	workloadDeploy   = "inject-test-terminus"
+
+	// unreadyDuration is how long a proxy is held unready, during
+	// which the readiness of its pod is checked
+	unreadyDuration = 30 * time.Second
+)
+
+// restoreDeploy returns a function scaling a control plane deployment back to
+// the given number of replicas. It can be called several times, and only
+// restores the deployment once
+func restoreDeploy(deploy, replicas string) func() {
+	h, _ := utils.GetHelperAndConfig()
+	restored := false
+
+	return func() {
+		if restored {
+			return
+		}
+		restored = true
+
+		ginkgo.By(fmt.Sprintf("Restoring deploy/%s to %s replica(s)", deploy, replicas))
+		err := utils.ScaleDeploy(h.GetLinkerdNamespace(), deploy, replicas)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to restore deploy/%s: %s", deploy, utils.Err(err)))
+		utils.TestControlPlanePostInstall(h)
+	}
+}
+
+// podReadiness holds the readiness of a pod, and of its containers
+type podReadiness struct {
+	name       string
+	pod        bool
+	proxy      bool
+	containers bool // all the containers other than the proxy are ready
+}
+
+func getWorkloadReadiness() (podReadiness, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	pods, err := h.GetPodsForDeployment(readinessNs(), workloadDeploy)
+	if err != nil {
+		return podReadiness{}, err
+	}
+	if len(pods) != 1 {
+		return podReadiness{}, fmt.Errorf("expected 1 pod for deploy/%s, got %d", workloadDeploy, len(pods))
+	}
+
+	pod := pods[0]
+	r := podReadiness{name: pod.GetName(), containers: true}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			r.pod = cond.Status == corev1.ConditionTrue
+		}
+	}
+
+	if len(pod.Status.ContainerStatuses) != len(pod.Spec.Containers) {
+		return r, fmt.Errorf("containers of pod/%s have not started yet", pod.GetName())
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == k8s.ProxyContainerName {
+			r.proxy = status.Ready
+		} else {
+			r.containers = r.containers && status.Ready
+		}
+	}
+	return r, nil
+}
+
+func createUnreadyWorkload() {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", readinessNs()))
+	err := h.CreateDataPlaneNamespaceIfNotExists(readinessNs(), map[string]string{
+		k8s.ProxyInjectAnnotation: k8s.ProxyInjectEnabled,
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", readinessNs(), utils.Err(err)))
+	utils.TrackNamespace(readinessNs())
+
+	err = utils.CopyImagePullSecrets(readinessNs())
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	manifest, err := utils.RenderManifest(workloadManifest)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Creating deploy/%s in namespace %s", workloadDeploy, readinessNs()))
+	out, err := h.KubectlApply(manifest, readinessNs())
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create deploy/%s: %s: %s", workloadDeploy, utils.Err(err), out))
+
+	err = utils.CheckProxyContainer(workloadDeploy, readinessNs())
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+// getEndpoints queries the destination controller for the endpoints of the web service of emojivoto
+func getEndpoints() (string, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	authority := fmt.Sprintf("web-svc.%s.svc.%s:80", emojivotoNs(), h.GetClusterDomain())
+	out, stderr, err := h.LinkerdRun("endpoints", authority)
+	if err != nil {
+		return "", fmt.Errorf("`linkerd endpoints %s` failed: %s: %s", authority, err, stderr)
+	}
+	return out, nil
+}
+
+func testAdminEndpoints() {
+	h, _ := utils.GetHelperAndConfig()
+	utils.EnsureEmojivoto(emojivotoNs())
+
+	for _, deploy := range utils.EmojivotoDeploys {
+		ginkgo.By(fmt.Sprintf("Port-forwarding to the proxy admin port of deploy/%s", deploy))
+		url := utils.GetProxyAdminURL(emojivotoNs(), deploy)
+
+		ginkgo.By(fmt.Sprintf("Checking `/ready` endpoint of deploy/%s", deploy))
+		body, err := h.HTTPGetURL(url + "/ready")
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("`/ready` check failed for deploy/%s: %s", deploy, utils.Err(err)))
+		gomega.Expect(strings.TrimSpace(body)).Should(gomega.Equal("ready"))
+
+		ginkgo.By(fmt.Sprintf("Checking `/live` endpoint of deploy/%s", deploy))
+		body, err = h.HTTPGetURL(url + "/live")
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("`/live` check failed for deploy/%s: %s", deploy, utils.Err(err)))
+		gomega.Expect(strings.TrimSpace(body)).Should(gomega.Equal("live"))
+
+		ginkgo.By(fmt.Sprintf("Checking `/metrics` endpoint of deploy/%s", deploy))
+		err = h.RetryFor(time.Minute, func() error {
+			body, err := h.HTTPGetURL(url + "/metrics")
+			if err != nil {
+				return err
+			}
+
+			for _, metric := range []string{"process_start_time_seconds", "request_total", "response_total"} {
+				if !strings.Contains(body, metric) {
+					return fmt.Errorf("could not find metric %s", metric)
+				}
+			}
+			return nil
+		})
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("`/metrics` check failed for deploy/%s: %s", deploy, utils.Err(err)))
+	}
+}
+
+func testProxyReadinessGatesPod() {
+	h, _ := utils.GetHelperAndConfig()
+	utils.EnsureEmojivoto(emojivotoNs())
+
+	for _, deploy := range utils.EmojivotoDeploys {
+		pods, err := h.GetPodsForDeployment(emojivotoNs(), deploy)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods for deploy/%s: %s", deploy, utils.Err(err)))
+
+		for _, pod := range pods {
+			ginkgo.By(fmt.Sprintf("Checking proxy probes of pod/%s", pod.GetName()))
+			proxy := testutil.GetProxyContainer(pod.Spec.Containers)
+			gomega.Expect(proxy).ShouldNot(gomega.BeNil(), fmt.Sprintf("could not find proxy container in pod/%s", pod.GetName()))
+
+			port, err := utils.GetProxyAdminPort(pod)
+			gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+			gomega.Expect(proxy.ReadinessProbe).ShouldNot(gomega.BeNil(), "proxy container has no readiness probe")
+			gomega.Expect(proxy.ReadinessProbe.HTTPGet).ShouldNot(gomega.BeNil(), "proxy readiness probe is not an HTTP probe")
+			gomega.Expect(proxy.ReadinessProbe.HTTPGet.Path).Should(gomega.Equal("/ready"))
+			gomega.Expect(proxy.ReadinessProbe.HTTPGet.Port.IntValue()).Should(gomega.Equal(port))
+
+			gomega.Expect(proxy.LivenessProbe).ShouldNot(gomega.BeNil(), "proxy container has no liveness probe")
+			gomega.Expect(proxy.LivenessProbe.HTTPGet).ShouldNot(gomega.BeNil(), "proxy liveness probe is not an HTTP probe")
+			gomega.Expect(proxy.LivenessProbe.HTTPGet.Path).Should(gomega.Equal("/live"))
+			gomega.Expect(proxy.LivenessProbe.HTTPGet.Port.IntValue()).Should(gomega.Equal(port))
+		}
+	}
+
+	// proxies only become ready once they obtained a certificate, hence
+	// new proxies are held unready while the identity controller is unavailable
+	replicas := utils.GetDeployReplicas(h.GetLinkerdNamespace(), identityDeploy)
+
+	ginkgo.By(fmt.Sprintf("Scaling down deploy/%s", identityDeploy))
+	err := utils.ScaleDeploy(h.GetLinkerdNamespace(), identityDeploy, "0")
+	restore := restoreDeploy(identityDeploy, replicas)
+	defer restore()
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to scale down deploy/%s: %s", identityDeploy, utils.Err(err)))
+
+	createUnreadyWorkload()
+
+	ginkgo.By(fmt.Sprintf("Waiting for the containers of deploy/%s other than the proxy to become ready", workloadDeploy))
+	err = h.RetryFor(3*time.Minute, func() error {
+		r, err := getWorkloadReadiness()
+		if err != nil {
+			return err
+		}
+		if !r.containers {
+			return fmt.Errorf("containers of pod/%s are not ready", r.name)
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Checking that deploy/%s is not ready while its proxy is held unready", workloadDeploy))
+	deadline := time.Now().Add(unreadyDuration)
+	for time.Now().Before(deadline) {
+		r, err := getWorkloadReadiness()
+		gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+		gomega.Expect(r.proxy).Should(gomega.BeFalse(), fmt.Sprintf("proxy of pod/%s became ready without an identity", r.name))
+		gomega.Expect(r.pod).Should(gomega.BeFalse(), fmt.Sprintf("pod/%s is ready while its proxy is not", r.name))
+		time.Sleep(5 * time.Second)
+	}
+
+	restore()
+
+	ginkgo.By(fmt.Sprintf("Checking that deploy/%s becomes ready along with its proxy", workloadDeploy))
+	err = h.RetryFor(3*time.Minute, func() error {
+		r, err := getWorkloadReadiness()
+		if err != nil {
+			return err
+		}
+		if !r.proxy || !r.pod {
+			return fmt.Errorf("pod/%s is not ready (pod ready: %t, proxy ready: %t)", r.name, r.pod, r.proxy)
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func testReadinessWithoutDestination() {
+	h, _ := utils.GetHelperAndConfig()
+	utils.EnsureEmojivoto(emojivotoNs())
+
+	urls := map[string]string{}
+	for _, deploy := range utils.EmojivotoDeploys {
+		urls[deploy] = utils.GetProxyAdminURL(emojivotoNs(), deploy)
+	}
+
+	ginkgo.By("Checking that endpoints can be discovered")
+	out, err := getEndpoints()
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+	gomega.Expect(out).Should(gomega.ContainSubstring("web"), "could not find the endpoints of the web service")
+
+	replicas := utils.GetDeployReplicas(h.GetLinkerdNamespace(), destinationDeploy)
+
+	ginkgo.By(fmt.Sprintf("Scaling down deploy/%s", destinationDeploy))
+	err = utils.ScaleDeploy(h.GetLinkerdNamespace(), destinationDeploy, "0")
+	restore := restoreDeploy(destinationDeploy, replicas)
+	defer restore()
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to scale down deploy/%s: %s", destinationDeploy, utils.Err(err)))
+
+	// lookups which have not been resolved by the proxies yet cannot be served
+	ginkgo.By("Checking that new endpoints cannot be discovered while the destination controller is unavailable")
+	err = h.RetryFor(time.Minute, func() error {
+		if _, err := getEndpoints(); err == nil {
+			return fmt.Errorf("endpoints were discovered while deploy/%s is unavailable", destinationDeploy)
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	// proxies that have already resolved their destinations must
+	// remain ready, even though new lookups cannot be served
+	ginkgo.By("Checking that proxies remain ready while the destination controller is unavailable")
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		for deploy, url := range urls {
+			body, err := h.HTTPGetURL(url + "/ready")
+			gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("proxy of deploy/%s became unready: %s", deploy, utils.Err(err)))
+			gomega.Expect(strings.TrimSpace(body)).Should(gomega.Equal("ready"))
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	for _, deploy := range utils.EmojivotoDeploys {
+		err := h.CheckDeployment(emojivotoNs(), deploy, 1)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("deploy/%s became unavailable: %s", deploy, utils.Err(err)))
+	}
+
+	restore()
+
+	ginkgo.By("Checking that endpoints can be discovered once the destination controller is restored")
+	err = h.RetryFor(time.Minute, func() error {
+		_, err := getEndpoints()
+		return err
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func testProxyCheck() {
+	h, _ := utils.GetHelperAndConfig()
+	utils.EnsureEmojivoto(emojivotoNs())
+	utils.RunProxyCheck(h, emojivotoNs())
+}
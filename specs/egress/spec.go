@@ -0,0 +1,24 @@
+package egress
+
+import (
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+)
+
+// RunEgressTests runs the specs for traffic to destinations outside the mesh
+func RunEgressTests() bool {
+	return ginkgo.Describe("egress: ", func() {
+		_, c := utils.GetHelperAndConfig()
+
+		_ = utils.ShouldTestSkip(c.SkipEgress(), "Skipping egress tests")
+		utils.RequireControlPlane(false)
+
+		ginkgo.It("can send requests to an unmeshed service", testUnmeshedService)
+
+		ginkgo.It("can send requests to an ExternalName service", testExternalNameService)
+
+		ginkgo.It("can send requests to an IP outside the mesh", testIPDestination)
+
+		ginkgo.It("can bypass the proxy for skipped outbound ports", testSkipOutboundPorts)
+	})
+}
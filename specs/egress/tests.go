@@ -0,0 +1,353 @@
+package egress
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+const (
+	externalManifest = "testdata/egress/external.yaml"
+	clientManifest   = "testdata/egress/client.yaml"
+
+	externalDeploy  = "external"
+	externalSvc     = "external-svc"
+	externalNameSvc = "external-name"
+	externalPort    = 8080
+
+	// externalResponse is the body of the responses of the external server
+	externalResponse = "external"
+
+	clientDeploy     = "client"
+	skipClientDeploy = "skip-client"
+
+	// tapTimeout is how long tap events are waited for
+	tapTimeout = 30 * time.Second
+)
+
+var (
+	// installed is set once the application has been installed by the current Ginkgo node
+	installed = false
+
+	// externalIP is the IP of the pod of the external server
+	externalIP = ""
+)
+
+// clientNs returns the namespace in which the injected clients are installed
+func clientNs() string {
+	return utils.GetSpecNamespace("egress")
+}
+
+// externalNs returns the namespace in which the external server is installed. It is not injected
+func externalNs() string {
+	return utils.GetSpecNamespace("egress-external")
+}
+
+// clientValues holds the values used for rendering `testdata/egress/client.yaml`
+type clientValues struct {
+	utils.TemplateValues
+	Name              string
+	ExternalNamespace string
+	Targets           []string
+}
+
+// statRow is used for unmarshalling the rows of `linkerd stat -o json`
+type statRow struct {
+	Name    string   `json:"name"`
+	Success *float64 `json:"success"`
+	Rps     *float64 `json:"rps"`
+}
+
+func getServiceAuthority() string {
+	return fmt.Sprintf("%s.%s:%d", externalSvc, externalNs(), externalPort)
+}
+
+func getExternalNameAuthority() string {
+	return fmt.Sprintf("%s:%d", externalNameSvc, externalPort)
+}
+
+func getIPAuthority() string {
+	return fmt.Sprintf("%s:%d", externalIP, externalPort)
+}
+
+func createNamespace(ns string) {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Creating namespace %s", ns))
+	err := h.CreateDataPlaneNamespaceIfNotExists(ns, nil)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", ns, utils.Err(err)))
+
+	// the application is used by all the specs, and is deleted once all of them complete
+	utils.TrackSuiteNamespace(ns)
+
+	err = utils.CopyImagePullSecrets(ns)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func checkDeploy(ns, deploy string) {
+	h, _ := utils.GetHelperAndConfig()
+
+	err := h.CheckPods(ns, deploy, 1)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to validate pods of deploy/%s: %s", deploy, utils.Err(err)))
+
+	err = h.CheckDeployment(ns, deploy, 1)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to validate deploy/%s: %s", deploy, utils.Err(err)))
+}
+
+// installClient installs a client sending requests to the given targets,
+// injected with the given flags
+func installClient(name string, targets []string, flags ...string) {
+	h, c := utils.GetHelperAndConfig()
+
+	manifest, err := utils.RenderTemplate(clientManifest, clientValues{
+		TemplateValues:    utils.GetTemplateValues(),
+		Name:              name,
+		ExternalNamespace: externalNs(),
+		Targets:           targets,
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	manifest, err = utils.ApplyImageConfig(manifest)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Injecting deploy/%s with flags %v", name, flags))
+	cmd := append([]string{"inject", "--manual"}, c.GetInjectImageFlags()...)
+	cmd = append(cmd, flags...)
+	out, stderr, err := h.PipeToLinkerdRun(manifest, append(cmd, "-")...)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to inject: %s", stderr))
+
+	out, err = h.KubectlApply(out, clientNs())
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply injected resources: %s: %s", utils.Err(err), out))
+
+	checkDeploy(clientNs(), name)
+}
+
+// ensureApplication installs the external server and the clients,
+// unless it has already been done by the current Ginkgo node
+func ensureApplication() {
+	h, _ := utils.GetHelperAndConfig()
+	if installed {
+		return
+	}
+
+	createNamespace(externalNs())
+	createNamespace(clientNs())
+
+	ginkgo.By(fmt.Sprintf("Installing the external server in namespace %s", externalNs()))
+	manifest, err := utils.RenderManifest(externalManifest)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	out, err := h.KubectlApply(manifest, externalNs())
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply the external server: %s: %s", utils.Err(err), out))
+
+	checkDeploy(externalNs(), externalDeploy)
+
+	pods, err := h.GetPodsForDeployment(externalNs(), externalDeploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods for deploy/%s: %s", externalDeploy, utils.Err(err)))
+	gomega.Expect(pods).ShouldNot(gomega.BeEmpty(), fmt.Sprintf("no pods found for deploy/%s", externalDeploy))
+	externalIP = pods[0].Status.PodIP
+
+	installClient(clientDeploy, []string{getServiceAuthority(), getExternalNameAuthority(), getIPAuthority()})
+	installClient(skipClientDeploy, []string{getServiceAuthority()}, "--skip-outbound-ports", fmt.Sprintf("%d", externalPort))
+
+	utils.RunProxyCheck(h, clientNs())
+	installed = true
+}
+
+// expectRequestSucceeds sends a request to authority from the given client
+func expectRequestSucceeds(deploy, authority string) {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Sending a request to %s from deploy/%s", authority, deploy))
+	err := h.RetryFor(time.Minute, func() error {
+		out, err := h.Kubectl("", "-n", clientNs(), "exec", "deploy/"+deploy, "-c", "client", "--",
+			"curl", "-s", "--max-time", "5", "-w", "\n%{http_code}", fmt.Sprintf("http://%s/", authority))
+		if err != nil {
+			return fmt.Errorf("request failed: %s: %s", err, out)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if status := lines[len(lines)-1]; status != "200" {
+			return fmt.Errorf("expected status 200, got %s: %s", status, out)
+		}
+
+		if !strings.Contains(out, externalResponse) {
+			return fmt.Errorf("unexpected response: %s", out)
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+// getOutboundRequests returns the outbound request metrics of the
+// proxy of the given client for the given authority
+func getOutboundRequests(adminURL, authority string) ([]utils.ProxyMetric, error) {
+	metrics, err := utils.GetProxyMetrics(adminURL, "request_total")
+	if err != nil {
+		return nil, err
+	}
+
+	requests := []utils.ProxyMetric{}
+	for _, m := range metrics {
+		if m.Labels["direction"] == "outbound" && m.Labels["authority"] == authority {
+			requests = append(requests, m)
+		}
+	}
+	return requests, nil
+}
+
+// expectNoIdentityMetrics checks that the proxy of the client reports
+// requests to authority, which are not secured by mTLS
+func expectNoIdentityMetrics(authority string) {
+	h, _ := utils.GetHelperAndConfig()
+	adminURL := utils.GetProxyAdminURL(clientNs(), clientDeploy)
+
+	ginkgo.By(fmt.Sprintf("Checking the metrics of the requests to %s", authority))
+	err := h.RetryFor(time.Minute, func() error {
+		requests, err := getOutboundRequests(adminURL, authority)
+		if err != nil {
+			return err
+		}
+
+		if len(requests) == 0 {
+			return fmt.Errorf("no outbound requests to %s reported by deploy/%s", authority, clientDeploy)
+		}
+
+		for _, m := range requests {
+			if tls := m.Labels["tls"]; tls != "no_identity" {
+				return fmt.Errorf("expected requests to %s to be reported with tls=\"no_identity\", got tls=%q", authority, tls)
+			}
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+// tapClient returns the tap events of the requests sent by
+// the given client to authority, read until timeout
+func tapClient(deploy, authority string, count int) ([]string, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	stream, err := h.LinkerdRunStream("tap", "deploy/"+deploy, "-n", clientNs(), "--authority", authority)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Stop()
+
+	events := []string{}
+	lines, err := stream.ReadUntil(count, tapTimeout)
+	for _, line := range lines {
+		if strings.HasPrefix(line, "req ") && strings.Contains(line, "proxy=out") {
+			events = append(events, line)
+		}
+	}
+	return events, err
+}
+
+// getTapField returns the value of a field of a tap event
+func getTapField(event, field string) string {
+	for _, f := range strings.Fields(event) {
+		if strings.HasPrefix(f, field+"=") {
+			return strings.TrimPrefix(f, field+"=")
+		}
+	}
+	return ""
+}
+
+// expectNoIdentityTap checks that the requests of the client to
+// authority are reported by tap, without mTLS
+func expectNoIdentityTap(authority string) {
+	ginkgo.By(fmt.Sprintf("Tapping the requests to %s", authority))
+	events, err := tapClient(clientDeploy, authority, 3)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to tap deploy/%s: %s", clientDeploy, utils.Err(err)))
+	gomega.Expect(events).ShouldNot(gomega.BeEmpty(), fmt.Sprintf("no outbound requests to %s tapped", authority))
+
+	for _, event := range events {
+		gomega.Expect(getTapField(event, "tls")).ShouldNot(gomega.Equal("true"), fmt.Sprintf("unexpected mTLS request to %s: %s", authority, event))
+	}
+}
+
+// testDestination validates the requests of the client to the given destination
+func testDestination(authority string) {
+	expectRequestSucceeds(clientDeploy, authority)
+	expectNoIdentityMetrics(authority)
+	expectNoIdentityTap(authority)
+}
+
+func testUnmeshedService() {
+	h, _ := utils.GetHelperAndConfig()
+	ensureApplication()
+
+	testDestination(getServiceAuthority())
+
+	ginkgo.By(fmt.Sprintf("Checking the stats of deploy/%s to deploy/%s", clientDeploy, externalDeploy))
+	err := h.RetryFor(2*time.Minute, func() error {
+		out, stderr, err := h.LinkerdRun("stat", "deploy/"+clientDeploy, "-n", clientNs(),
+			"--to", "deploy/"+externalDeploy, "--to-namespace", externalNs(), "-o", "json")
+		if err != nil {
+			return fmt.Errorf("`linkerd stat` failed: %s: %s", err, stderr)
+		}
+
+		var rows []statRow
+		if err := json.Unmarshal([]byte(out), &rows); err != nil {
+			return fmt.Errorf("failed to parse the stats: %s: %s", err, out)
+		}
+
+		for _, row := range rows {
+			if row.Name != clientDeploy {
+				continue
+			}
+
+			if row.Rps == nil || *row.Rps == 0 || row.Success == nil || *row.Success == 0 {
+				return fmt.Errorf("no successful requests reported from deploy/%s to deploy/%s: %s", clientDeploy, externalDeploy, out)
+			}
+			return nil
+		}
+		return fmt.Errorf("no stats reported for deploy/%s: %s", clientDeploy, out)
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func testExternalNameService() {
+	ensureApplication()
+	testDestination(getExternalNameAuthority())
+}
+
+func testIPDestination() {
+	ensureApplication()
+	testDestination(getIPAuthority())
+}
+
+func testSkipOutboundPorts() {
+	h, _ := utils.GetHelperAndConfig()
+	ensureApplication()
+
+	ginkgo.By(fmt.Sprintf("Checking the skipped outbound ports of deploy/%s", skipClientDeploy))
+	out, err := h.Kubectl("", "-n", clientNs(), "get", "deploy", skipClientDeploy,
+		"-o", `jsonpath={.spec.template.metadata.annotations.config\.linkerd\.io/skip-outbound-ports}`)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get deploy/%s: %s: %s", skipClientDeploy, utils.Err(err), out))
+	gomega.Expect(strings.TrimSpace(out)).Should(gomega.Equal(fmt.Sprintf("%d", externalPort)), "unexpected skip-outbound-ports annotation")
+
+	authority := getServiceAuthority()
+	expectRequestSucceeds(skipClientDeploy, authority)
+
+	// the client has been sending requests since it was installed,
+	// none of which must have gone through its proxy
+	ginkgo.By(fmt.Sprintf("Checking that the requests to %s bypass the proxy", authority))
+	requests, err := getOutboundRequests(utils.GetProxyAdminURL(clientNs(), skipClientDeploy), authority)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+	gomega.Expect(requests).Should(gomega.BeEmpty(), fmt.Sprintf("requests to %s were reported by the proxy of deploy/%s", authority, skipClientDeploy))
+
+	// tap times out, as no events are received. Any other error means
+	// that the requests could not be tapped at all
+	events, err := tapClient(skipClientDeploy, authority, 1)
+	if !utils.IsStreamTimeout(err) {
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to tap deploy/%s: %s", skipClientDeploy, utils.Err(err)))
+	}
+	gomega.Expect(events).Should(gomega.BeEmpty(), fmt.Sprintf("requests to %s were tapped", authority))
+}
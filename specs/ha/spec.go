@@ -0,0 +1,28 @@
+package ha
+
+import (
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+)
+
+// RunHATests runs the specs for high-availability control planes
+func RunHATests() bool {
+	return ginkgo.Describe("high availability: ", func() {
+		_, c := utils.GetHelperAndConfig()
+
+		_ = utils.ShouldTestSkip(c.SkipHA(), "Skipping high availability tests")
+		_ = utils.ShouldTestSkip(!c.HA(), "Skipping high availability tests as `controlPlane.config.ha` is not set")
+		_ = utils.SkipIfUnsupported(utils.GetCapabilities().ControlPlaneUnsupportedReason(true))
+
+		// draining nodes and deleting control plane pods affects all the meshed workloads
+		utils.RequireControlPlane(true)
+
+		ginkgo.It("can spread the replicas of each component across nodes", testAntiAffinity)
+
+		ginkgo.It("can set the default HA resource requests and limits", testResources)
+
+		ginkgo.It("can honour PodDisruptionBudgets during a node drain", testPodDisruptionBudgets)
+
+		ginkgo.It("can keep serving while one replica of each component is deleted", testReplicaDeletion)
+	})
+}
@@ -0,0 +1,282 @@
+package ha
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/testutil"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	hostnameTopologyKey = "kubernetes.io/hostname"
+	drainedDeploy       = "linkerd-destination"
+	drainTimeout        = "300s"
+)
+
+// resourceSpec holds the resource requests and limits expected of a container
+type resourceSpec struct {
+	cpuRequest, cpuLimit, memoryRequest, memoryLimit string
+}
+
+var (
+	controllerResources = resourceSpec{"100m", "1", "50Mi", "250Mi"}
+
+	// haResources maps each control plane deployment to the resources
+	// its main container is expected to have, as set by `linkerd install --ha`
+	haResources = map[string]resourceSpec{
+		"linkerd-controller":     controllerResources,
+		"linkerd-destination":    controllerResources,
+		"linkerd-identity":       {"100m", "1", "10Mi", "250Mi"},
+		"linkerd-proxy-injector": controllerResources,
+		"linkerd-sp-validator":   controllerResources,
+		"linkerd-tap":            controllerResources,
+		"linkerd-web":            controllerResources,
+		"linkerd-prometheus":     {"300m", "4", "300Mi", "8192Mi"},
+		"linkerd-grafana":        {"100m", "1", "50Mi", "1024Mi"},
+	}
+
+	// haProxyResources are the resources expected of the proxies of the control plane
+	haProxyResources = resourceSpec{"100m", "1", "20Mi", "250Mi"}
+
+	// haMainContainers maps the deployments in haResources which are not
+	// in utils.HADeploys to their main container
+	haMainContainers = map[string]string{
+		"linkerd-web":        "web",
+		"linkerd-prometheus": "prometheus",
+		"linkerd-grafana":    "grafana",
+	}
+)
+
+func getMainContainer(deploy string) string {
+	if container, ok := utils.HADeploys[deploy]; ok {
+		return container
+	}
+	return haMainContainers[deploy]
+}
+
+func checkQuantity(name, expected string, actual corev1.ResourceList, resourceName corev1.ResourceName) error {
+	q, ok := actual[resourceName]
+	if !ok {
+		return fmt.Errorf("%s: expected %s, but it is not set", name, expected)
+	}
+
+	if q.Cmp(resource.MustParse(expected)) != 0 {
+		return fmt.Errorf("%s: expected %s, got %s", name, expected, q.String())
+	}
+	return nil
+}
+
+func checkResources(deploy, container string, expected resourceSpec) {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Checking resources of container %s in deploy/%s", container, deploy))
+	res, err := h.GetResources(container, deploy, h.GetLinkerdNamespace())
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get resources of deploy/%s: %s", deploy, utils.Err(err)))
+
+	for _, err := range []error{
+		checkQuantity("cpu request", expected.cpuRequest, res.Requests, corev1.ResourceCPU),
+		checkQuantity("cpu limit", expected.cpuLimit, res.Limits, corev1.ResourceCPU),
+		checkQuantity("memory request", expected.memoryRequest, res.Requests, corev1.ResourceMemory),
+		checkQuantity("memory limit", expected.memoryLimit, res.Limits, corev1.ResourceMemory),
+	} {
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("unexpected resources for container %s in deploy/%s: %s", container, deploy, utils.Err(err)))
+	}
+}
+
+func getDeployment(deploy string) *appsv1.Deployment {
+	h, _ := utils.GetHelperAndConfig()
+
+	out, err := h.Kubectl("", "-n", h.GetLinkerdNamespace(), "get", "deploy", deploy, "-o", "json")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get deploy/%s: %s: %s", deploy, utils.Err(err), out))
+
+	var d appsv1.Deployment
+	err = json.Unmarshal([]byte(out), &d)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to parse deploy/%s: %s", deploy, utils.Err(err)))
+	return &d
+}
+
+func hasHostnameAntiAffinity(d *appsv1.Deployment) bool {
+	affinity := d.Spec.Template.Spec.Affinity
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return false
+	}
+
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if term.TopologyKey == hostnameTopologyKey {
+			return true
+		}
+	}
+	return false
+}
+
+func testAntiAffinity() {
+	h, _ := utils.GetHelperAndConfig()
+
+	for deploy := range utils.HADeploys {
+		ginkgo.By(fmt.Sprintf("Checking pod anti-affinity of deploy/%s", deploy))
+		d := getDeployment(deploy)
+		gomega.Expect(hasHostnameAntiAffinity(d)).Should(gomega.BeTrue(),
+			fmt.Sprintf("deploy/%s has no required pod anti-affinity on %s", deploy, hostnameTopologyKey))
+
+		ginkgo.By(fmt.Sprintf("Checking that the pods of deploy/%s run on different nodes", deploy))
+		pods, err := h.GetPodsForDeployment(h.GetLinkerdNamespace(), deploy)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods for deploy/%s: %s", deploy, utils.Err(err)))
+		gomega.Expect(pods).Should(gomega.HaveLen(utils.HAReplicas), fmt.Sprintf("unexpected number of pods for deploy/%s", deploy))
+
+		nodes := map[string]string{}
+		for _, pod := range pods {
+			if other, ok := nodes[pod.Spec.NodeName]; ok {
+				ginkgo.Fail(fmt.Sprintf("pod/%s and pod/%s of deploy/%s are both scheduled on node %s", pod.GetName(), other, deploy, pod.Spec.NodeName))
+			}
+			nodes[pod.Spec.NodeName] = pod.GetName()
+		}
+	}
+}
+
+func testResources() {
+	_, c := utils.GetHelperAndConfig()
+
+	for deploy, expected := range haResources {
+		if !c.IsComponentEnabled(deploy) {
+			continue
+		}
+
+		checkResources(deploy, getMainContainer(deploy), expected)
+		checkResources(deploy, k8s.ProxyContainerName, haProxyResources)
+	}
+}
+
+func getPodDisruptionBudgets() []policyv1beta1.PodDisruptionBudget {
+	h, _ := utils.GetHelperAndConfig()
+
+	out, err := h.Kubectl("", "-n", h.GetLinkerdNamespace(), "get", "pdb", "-o", "json")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get PodDisruptionBudgets: %s: %s", utils.Err(err), out))
+
+	var pdbs policyv1beta1.PodDisruptionBudgetList
+	err = json.Unmarshal([]byte(out), &pdbs)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to parse PodDisruptionBudgets: %s", utils.Err(err)))
+	return pdbs.Items
+}
+
+// checkPodDisruptionBudgets checks that none of the
+// budgets allow less pods than they require to be healthy
+func checkPodDisruptionBudgets() error {
+	for _, pdb := range getPodDisruptionBudgets() {
+		if pdb.Status.CurrentHealthy < pdb.Status.DesiredHealthy {
+			return fmt.Errorf("pdb/%s has %d healthy pod(s), %d required", pdb.GetName(), pdb.Status.CurrentHealthy, pdb.Status.DesiredHealthy)
+		}
+	}
+	return nil
+}
+
+func testPodDisruptionBudgets() {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By("Checking PodDisruptionBudgets of the control plane")
+	if len(getPodDisruptionBudgets()) == 0 {
+		ginkgo.Skip(fmt.Sprintf("Linkerd %s does not create PodDisruptionBudgets", h.GetVersion()))
+	}
+
+	pods, err := h.GetPodsForDeployment(h.GetLinkerdNamespace(), drainedDeploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods for deploy/%s: %s", drainedDeploy, utils.Err(err)))
+	gomega.Expect(pods).ShouldNot(gomega.BeEmpty(), fmt.Sprintf("no pods found for deploy/%s", drainedDeploy))
+	node := pods[0].Spec.NodeName
+
+	ginkgo.By(fmt.Sprintf("Draining node %s", node))
+	out, err := h.Kubectl("", "drain", node, "--ignore-daemonsets", "--delete-local-data", "--timeout="+drainTimeout)
+	defer func() {
+		ginkgo.By(fmt.Sprintf("Uncordoning node %s", node))
+		out, err := h.Kubectl("", "uncordon", node)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to uncordon node %s: %s: %s", node, utils.Err(err), out))
+
+		utils.TestControlPlanePostInstall(h)
+		utils.RunCheck(h, false)
+	}()
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to drain node %s: %s: %s", node, utils.Err(err), out))
+
+	ginkgo.By("Checking that the PodDisruptionBudgets were honoured")
+	err = checkPodDisruptionBudgets()
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+// deleteReplicas deletes one pod of each of the replicated control plane components
+func deleteReplicas() {
+	h, _ := utils.GetHelperAndConfig()
+
+	for deploy := range utils.HADeploys {
+		pods, err := h.GetPodNamesForDeployment(h.GetLinkerdNamespace(), deploy)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods for deploy/%s: %s", deploy, utils.Err(err)))
+		gomega.Expect(pods).ShouldNot(gomega.BeEmpty(), fmt.Sprintf("no pods found for deploy/%s", deploy))
+
+		ginkgo.By(fmt.Sprintf("Deleting pod/%s of deploy/%s", pods[0], deploy))
+		out, err := h.Kubectl("", "-n", h.GetLinkerdNamespace(), "delete", "pod", pods[0], "--wait=false")
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to delete pod/%s: %s: %s", pods[0], utils.Err(err), out))
+	}
+}
+
+// testInjectionAndIdentity deploys a workload in a namespace with automatic
+// injection enabled, and checks that it is injected and receives an identity
+func testInjectionAndIdentity() {
+	h, _ := utils.GetHelperAndConfig()
+
+	ns := utils.GetSpecNamespace("ha-inject")
+	deploy := "inject-test-terminus"
+
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", ns))
+	err := h.CreateDataPlaneNamespaceIfNotExists(ns, map[string]string{
+		k8s.ProxyInjectAnnotation: k8s.ProxyInjectEnabled,
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", ns, utils.Err(err)))
+	utils.TrackNamespace(ns)
+
+	err = utils.CopyImagePullSecrets(ns)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	manifest, err := utils.RenderManifest("testdata/inject/inject_test.yaml")
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Creating deploy/%s in namespace %s", deploy, ns))
+	out, err := h.KubectlApply(manifest, ns)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create deploy/%s: %s: %s", deploy, utils.Err(err), out))
+
+	// the proxy only becomes ready once it has been issued a
+	// certificate, hence a successful rollout validates identity
+	ginkgo.By(fmt.Sprintf("Waiting for deploy/%s to be ready", deploy))
+	out, err = h.Kubectl("", "-n", ns, "rollout", "status", "--timeout=180s", "deploy/"+deploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("deploy/%s did not become ready: %s: %s", deploy, utils.Err(err), out))
+
+	ginkgo.By(fmt.Sprintf("Checking that deploy/%s was injected", deploy))
+	err = utils.CheckProxyContainer(deploy, ns)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func testReplicaDeletion() {
+	h, _ := utils.GetHelperAndConfig()
+
+	emojivotoNs := utils.GetSpecNamespace("ha-emojivoto")
+	utils.EnsureEmojivoto(emojivotoNs)
+
+	deleteReplicas()
+
+	ginkgo.By("Checking that meshed traffic keeps flowing")
+	err := h.RetryFor(time.Minute, func() error {
+		return testutil.ExerciseTestAppEndpoint("/api/list", emojivotoNs, h)
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to exercise emojivoto endpoint: %s", utils.Err(err)))
+
+	testInjectionAndIdentity()
+
+	utils.RunCheck(h, false)
+
+	ginkgo.By("Waiting for the deleted replicas to be replaced")
+	utils.TestControlPlanePostInstall(h)
+}
@@ -1,6 +1,8 @@
 package ingress
 
 import (
+	"fmt"
+
 	"github.com/linkerd/linkerd2-conformance/utils"
 	"github.com/onsi/ginkgo"
 )
@@ -11,16 +13,25 @@ func RunIngressTests() bool {
 		_, c := utils.GetHelperAndConfig()
 
 		_ = utils.ShouldTestSkip(c.SkipIngress(), "Skipping ingress tests")
+		_ = utils.SkipIfUnsupported(getUnsupportedReason())
+		utils.RequireControlPlane(false)
 
 		ginkgo.It("can install and inject emojivoto app", func() {
-			utils.TestEmojivotoApp()
-			utils.TestEmojivotoInject()
+			utils.TestEmojivotoApp(emojivotoNs())
+			utils.TestEmojivotoInject(emojivotoNs())
 		})
 
-		if c.ShouldTestIngressOfType(utils.Nginx) {
-			ginkgo.It("can work with nginx ingress controller", testNginx)
+		for _, ctrl := range getIngressControllers() {
+			ctrl := ctrl
+			if c.ShouldTestIngressOfType(ctrl.name) {
+				ginkgo.It(fmt.Sprintf("can work with %s ingress controller", ctrl.name), func() {
+					testIngressController(ctrl)
+				})
+			}
 		}
 
-		ginkgo.It("can uninstall emojivoto app", utils.TestEmojivotoUninstall)
+		ginkgo.It("can uninstall emojivoto app", func() {
+			utils.TestEmojivotoUninstall(emojivotoNs())
+		})
 	})
 }
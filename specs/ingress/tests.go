@@ -11,13 +11,18 @@ import (
 	"github.com/onsi/gomega"
 )
 
-func pingEmojivoto(ip string) error {
+// emojivotoNs returns the namespace in which emojivoto is installed for the ingress tests
+func emojivotoNs() string {
+	return utils.GetSpecNamespace("ingress-emojivoto")
+}
+
+func pingEmojivoto(ip, host string, statusCode int) error {
 	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s", ip), nil)
 	if err != nil {
 		return err
 	}
 
-	req.Host = "example.com"
+	req.Host = host
 
 	client := http.Client{
 		Timeout: 3 * time.Minute,
@@ -30,78 +35,401 @@ func pingEmojivoto(ip string) error {
 
 	defer res.Body.Close()
 
-	if res.StatusCode != 200 {
-		return fmt.Errorf("did not recieve status code 200. Recieved %d", res.StatusCode)
+	if res.StatusCode != statusCode {
+		return fmt.Errorf("did not recieve status code %d. Recieved %d", statusCode, res.StatusCode)
 	}
 	return nil
 
 }
 
-func getExternalIP(svc, ns string) (string, error) {
+func getLoadBalancerAddress(svc, ns string) (string, error) {
 	h, _ := utils.GetHelperAndConfig()
-	var ip string
-	var err error
+	var addr string
 
-	err = h.RetryFor(time.Minute*5, func() error {
-		ip, err = h.Kubectl("", "get", "svc", "-n", ns, svc, "-o", "jsonpath='{.status.loadBalancer.ingress[0].ip}'")
+	err := h.RetryFor(time.Minute*5, func() error {
+		out, err := h.Kubectl("", "get", "svc", "-n", ns, svc, "-o", "jsonpath='{.status.loadBalancer.ingress[0].ip} {.status.loadBalancer.ingress[0].hostname}'")
 		if err != nil {
 			return fmt.Errorf("failed to fetch external IP: %s", err.Error())
 		}
-		if strings.Trim(ip, "'") == "" {
-			return fmt.Errorf("IP address is empty")
+
+		// some providers (such as AWS) hand out hostnames instead of IPs
+		fields := strings.Fields(strings.Trim(out, "'"))
+		if len(fields) == 0 {
+			return fmt.Errorf("IP address and hostname are empty")
 		}
+		addr = fields[0]
 		return nil
 	})
 	if err != nil {
 		return "", err
 	}
-	return strings.Trim(ip, "'"), nil
+	return addr, nil
+}
+
+func getNodePortAddress(ctrl ingressController) (string, error) {
+	h, c := utils.GetHelperAndConfig()
+
+	port, err := h.Kubectl("", "get", "svc", "-n", ctrl.namespace, ctrl.svc, "-o", "jsonpath={.spec.ports[?(@.port==80)].nodePort}")
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch node port: %s", err.Error())
+	}
+	if port = strings.TrimSpace(port); port == "" {
+		return "", fmt.Errorf("svc/%s does not expose a node port", ctrl.svc)
+	}
+
+	if addr := c.GetIngressNodeAddress(); addr != "" {
+		return fmt.Sprintf("%s:%s", addr, port), nil
+	}
+
+	// services with `externalTrafficPolicy: Local` are only
+	// reachable through the nodes running the controller pods
+	pods, err := h.GetPodsForDeployment(ctrl.namespace, ctrl.deploy)
+	if err != nil || len(pods) == 0 {
+		return "", fmt.Errorf("could not get pod(s) for deployment %s: %s", ctrl.deploy, utils.Err(err))
+	}
+
+	node := pods[0].Spec.NodeName
+	addrs, err := h.Kubectl("", "get", "node", node, "-o", "jsonpath={.status.addresses[?(@.type==\"ExternalIP\")].address} {.status.addresses[?(@.type==\"InternalIP\")].address}")
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch addresses of node %s: %s", node, err.Error())
+	}
+
+	fields := strings.Fields(addrs)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("could not find an address for node %s", node)
+	}
+	return fmt.Sprintf("%s:%s", fields[0], port), nil
+}
+
+func getPortForwardAddress(ctrl ingressController) (string, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	url, err := h.URLFor(ctrl.namespace, ctrl.deploy, ctrl.port)
+	if err != nil {
+		return "", fmt.Errorf("failed to port-forward to deploy/%s: %s", ctrl.deploy, err.Error())
+	}
+	return strings.TrimPrefix(url, "http://"), nil
+}
+
+const reachabilityJobPath = "testdata/ingress/reachability_job.yaml"
+
+// reachabilityJobValues holds the values used for rendering reachabilityJobPath
+type reachabilityJobValues struct {
+	utils.TemplateValues
+	Name      string
+	Namespace string
+	Script    string
+}
+
+// pingEmojivotoFromCluster sends requests to the ingress controller's
+// service from a Job running inside the cluster, until the expected
+// status code is received
+func pingEmojivotoFromCluster(ctrl ingressController) error {
+	h, _ := utils.GetHelperAndConfig()
+	job := "ingress-reachability"
+
+	url := fmt.Sprintf("http://%s.%s.svc.%s", ctrl.svc, ctrl.namespace, h.GetClusterDomain())
+	script := fmt.Sprintf(`for i in $(seq 1 36); do
+  code=$(curl -s -o /dev/null -w '%%{http_code}' -H 'Host: %s' %s)
+  [ "$code" = "%d" ] && exit 0
+  sleep 5
+done
+echo "last received status code: $code"
+exit 1`, ctrl.host, url, ctrl.statusCode)
+
+	manifest, err := utils.RenderTemplate(reachabilityJobPath, reachabilityJobValues{
+		TemplateValues: utils.GetTemplateValues(),
+		Name:           job,
+		Namespace:      ctrl.namespace,
+		Script:         script,
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest, err = utils.ApplyImageConfig(manifest)
+	if err != nil {
+		return err
+	}
+
+	out, err := h.Kubectl(manifest, "create", "-f", "-")
+	if err != nil {
+		return fmt.Errorf("failed to create job/%s: %s: %s", job, err.Error(), out)
+	}
+
+	defer h.Kubectl("", "-n", ctrl.namespace, "delete", "job", job, "--ignore-not-found")
+
+	out, err = h.Kubectl("", "-n", ctrl.namespace, "wait", "--for=condition=complete", "--timeout=240s", "job/"+job)
+	if err != nil {
+		logs, _ := h.Kubectl("", "-n", ctrl.namespace, "logs", "job/"+job)
+		return fmt.Errorf("job/%s did not complete: %s: %s", job, err.Error(), logs)
+	}
+	return nil
+}
+
+// checkReachability checks if emojivoto can be reached through the
+// ingress controller using the configured reachability strategy
+func checkReachability(ctrl ingressController) error {
+	h, c := utils.GetHelperAndConfig()
+
+	var addr string
+	var err error
+
+	switch c.GetIngressReachability() {
+	case utils.ReachabilityInCluster:
+		return pingEmojivotoFromCluster(ctrl)
+	case utils.ReachabilityNodePort:
+		addr, err = getNodePortAddress(ctrl)
+	case utils.ReachabilityPortForward:
+		addr, err = getPortForwardAddress(ctrl)
+	default:
+		addr, err = getLoadBalancerAddress(ctrl.svc, ctrl.namespace)
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.RetryFor(3*time.Minute, func() error {
+		return pingEmojivoto(addr, ctrl.host, ctrl.statusCode)
+	})
+}
+
+// ingressController describes how an ingress controller
+// is installed and exposed for testing
+type ingressController struct {
+	name      string
+	namespace string
+
+	// deploy is the deployment that proxies the ingress traffic,
+	// and is injected with the linkerd proxy
+	deploy string
+
+	// svc is the service exposing deploy outside the cluster on port 80
+	svc string
+
+	// port is the container port on which deploy serves HTTP traffic
+	port int
+
+	controllerManifest string
+
+	// resourceTemplate is rendered with resourceValues
+	resourceTemplate string
+
+	// ingressAPIVersions holds the Ingress APIs watched by the controller, newest first.
+	// The resource is rendered with the newest of them served by the cluster. It is
+	// empty for controllers configured through their own resources rather than an Ingress
+	ingressAPIVersions []string
+
+	// host is sent as the Host header, and statusCode is expected
+	// in response to requests sent through the controller
+	host       string
+	statusCode int
+
+	// waitDeploys must be available within waitTimeout before the
+	// controller is injected. If empty, all deployments in namespace are waited on
+	waitDeploys []string
+	waitTimeout time.Duration
+}
+
+// resourceValues holds the values available to ingress resource templates
+type resourceValues struct {
+	utils.TemplateValues
+
+	Host        string
+	Namespace   string
+	Service     string
+	ServicePort int
+
+	// IngressAPIVersion is the newest Ingress API served by the cluster and watched by the controller
+	IngressAPIVersion string
+}
+
+const (
+	networkingV1beta1 = "networking.k8s.io/v1beta1"
+	extensionsV1beta1 = "extensions/v1beta1"
+)
+
+// getNewestAPIVersion returns the first group version in versions
+// that serves resource, or an empty string if none of them do
+func getNewestAPIVersion(versions []string, resource string) (string, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	for _, version := range versions {
+		ok, err := utils.SupportsResource(h, version, resource)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+func newBuiltinController(name, namespace, deploy string, port int, ingressAPIVersions ...string) ingressController {
+	return ingressController{
+		name:               name,
+		namespace:          namespace,
+		deploy:             deploy,
+		svc:                deploy,
+		port:               port,
+		ingressAPIVersions: ingressAPIVersions,
+		controllerManifest: fmt.Sprintf("testdata/ingress/controllers/%s.yaml", name),
+		resourceTemplate:   fmt.Sprintf("testdata/ingress/resources/%s.yaml", name),
+		host:               "example.com",
+		statusCode:         http.StatusOK,
+		waitTimeout:        5 * time.Minute,
+	}
+}
+
+// the bundled versions of the controllers predate `networking.k8s.io/v1`, hence
+// they only run along with custom controllers on clusters serving v1 only.
+// Contour is configured through an HTTPProxy rather than an Ingress
+var builtinControllers = []ingressController{
+	newBuiltinController(utils.Nginx, utils.NginxNs, utils.NginxController, 80, networkingV1beta1, extensionsV1beta1),
+	newBuiltinController(utils.Traefik, utils.TraefikNs, utils.TraefikController, 80, extensionsV1beta1),
+	newBuiltinController(utils.Ambassador, utils.AmbassadorNs, utils.AmbassadorController, 8080, extensionsV1beta1),
+	newBuiltinController(utils.Gloo, utils.GlooNs, utils.GlooController, 8080, extensionsV1beta1),
+	newBuiltinController(utils.Contour, utils.ContourNs, utils.ContourController, 8080),
+}
+
+// getIngressControllers returns the built-in ingress
+// controllers along with the user-defined ones
+func getIngressControllers() []ingressController {
+	_, c := utils.GetHelperAndConfig()
+
+	controllers := append([]ingressController{}, builtinControllers...)
+	for _, custom := range c.GetCustomIngressControllers() {
+		// the timeout has already been validated while parsing the config
+		timeout, _ := time.ParseDuration(custom.Readiness.Timeout)
+
+		ingressAPIVersions := utils.IngressAPIVersions
+		if len(custom.IngressAPIVersions) > 0 {
+			ingressAPIVersions = custom.IngressAPIVersions
+		}
+
+		controllers = append(controllers, ingressController{
+			name:               custom.Name,
+			namespace:          custom.Namespace,
+			deploy:             custom.Deployment,
+			svc:                custom.Service,
+			port:               custom.Port,
+			controllerManifest: custom.Manifest,
+			resourceTemplate:   custom.Resource,
+			host:               custom.Host,
+			statusCode:         custom.Readiness.StatusCode,
+			waitDeploys:        custom.Readiness.Deployments,
+			waitTimeout:        timeout,
+			ingressAPIVersions: ingressAPIVersions,
+		})
+	}
+	return controllers
 }
 
-func testNginx() {
+// getUnsupportedReason returns the reason for which the ingress
+// tests cannot run in the cluster, or an empty string if they can
+func getUnsupportedReason() string {
+	caps := utils.GetCapabilities()
+	if reason := caps.LoadBalancerUnsupportedReason(); reason != "" {
+		return reason
+	}
+
+	for _, version := range utils.IngressAPIVersions {
+		if caps.SupportsAPI(version) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("the cluster does not serve any of the Ingress APIs %v", utils.IngressAPIVersions)
+}
+
+func renderResource(ctrl ingressController, ingressAPIVersion string) (string, error) {
+	return utils.RenderTemplate(ctrl.resourceTemplate, resourceValues{
+		TemplateValues:    utils.GetTemplateValues(),
+		Host:              ctrl.host,
+		Namespace:         emojivotoNs(),
+		Service:           "web-svc",
+		ServicePort:       80,
+		IngressAPIVersion: ingressAPIVersion,
+	})
+}
+
+// applyManifest applies a manifest, retrying for a while as custom
+// resources cannot be created until their CRDs are established
+func applyManifest(manifest string) error {
 	h, _ := utils.GetHelperAndConfig()
-	ginkgo.By("Creating ingress-nginx controller")
-	_, err := h.Kubectl("", "apply", "-f", "testdata/ingress/controllers/nginx.yaml")
+	return h.RetryFor(time.Minute, func() error {
+		out, err := h.Kubectl(manifest, "apply", "-f", "-")
+		if err != nil {
+			return fmt.Errorf("%s: %s", err.Error(), out)
+		}
+		return nil
+	})
+}
+
+func testIngressController(ctrl ingressController) {
+	h, c := utils.GetHelperAndConfig()
+
+	ingressAPIVersion, err := getNewestAPIVersion(ctrl.ingressAPIVersions, "ingresses")
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+	if ingressAPIVersion == "" && len(ctrl.ingressAPIVersions) > 0 {
+		ginkgo.Skip(fmt.Sprintf("Skipping %s ingress controller: the cluster does not serve any of the Ingress APIs it watches %v", ctrl.name, ctrl.ingressAPIVersions))
+	}
+
+	utils.EnsureEmojivoto(emojivotoNs())
+
+	ginkgo.By(fmt.Sprintf("Creating %s ingress controller", ctrl.name))
+	controller, err := utils.RenderManifest(ctrl.controllerManifest)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 
+	utils.TrackManifest(controller)
+	err = applyManifest(controller)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create controller: %s", utils.Err(err)))
 
-	err = h.CheckPods(utils.NginxNs, utils.NginxController, 1)
+	waitFor := []string{"deploy", "--all"}
+	if len(ctrl.waitDeploys) > 0 {
+		waitFor = []string{}
+		for _, deploy := range ctrl.waitDeploys {
+			waitFor = append(waitFor, "deploy/"+deploy)
+		}
+	}
+
+	ginkgo.By(fmt.Sprintf("Waiting for deployments in namespace %s to be available", ctrl.namespace))
+	o, err := h.Kubectl("", append([]string{"-n", ctrl.namespace, "wait", "--for=condition=available", fmt.Sprintf("--timeout=%s", ctrl.waitTimeout)}, waitFor...)...)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to wait for deployments in namespace %s: %s: %s", ctrl.namespace, utils.Err(err), o))
+
+	err = h.CheckPods(ctrl.namespace, ctrl.deploy, 1)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to verify controller pods: %s", utils.Err(err)))
 
 	ginkgo.By("Injecting linkerd into the ingress controller pods")
-	out, err := h.Kubectl("", "get", "-n", utils.NginxNs, "deploy", utils.NginxController, "-o", "yaml")
-	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get YAML manifest for deploy/%s: %s", utils.NginxController, utils.Err(err)))
+	out, err := h.Kubectl("", "get", "-n", ctrl.namespace, "deploy", ctrl.deploy, "-o", "yaml")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get YAML manifest for deploy/%s: %s", ctrl.deploy, utils.Err(err)))
 
-	out, stderr, err := h.PipeToLinkerdRun(out, "inject", "-")
+	cmd := append([]string{"inject"}, c.GetInjectImageFlags()...)
+	out, stderr, err := h.PipeToLinkerdRun(out, append(cmd, "-")...)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to inject: %s", stderr))
 
-	_, err = h.KubectlApply(out, utils.NginxNs)
+	_, err = h.KubectlApply(out, ctrl.namespace)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply injected manifests: %s", utils.Err(err)))
 
-	err = h.CheckPods(utils.NginxNs, utils.NginxController, 1)
+	err = h.CheckPods(ctrl.namespace, ctrl.deploy, 1)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to verify controller pods: %s", utils.Err(err)))
 
 	ginkgo.By("Verifying if ingress controller pods have been injected")
 	// Wait upto 3mins for proxy container to show up
-	err = utils.CheckProxyContainer(utils.NginxController, utils.NginxNs)
+	err = utils.CheckProxyContainer(ctrl.deploy, ctrl.namespace)
 	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 
-	ginkgo.By("Applying ingress resource")
-	_, err = h.Kubectl("", "apply", "-f", "testdata/ingress/resources/nginx.yaml")
-	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create ingress resource: %s", utils.Err(err)))
+	utils.RunProxyCheck(h, ctrl.namespace)
 
-	ginkgo.By("Checking if emojivoto is reachable")
-	ip, err := getExternalIP(utils.NginxController, utils.NginxNs)
+	ginkgo.By("Applying ingress resource")
+	resource, err := renderResource(ctrl, ingressAPIVersion)
 	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 
-	err = h.RetryFor(3*time.Minute, func() error {
-		return pingEmojivoto(strings.Trim(ip, "'"))
-	})
+	utils.TrackManifest(resource)
+	err = applyManifest(resource)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create ingress resource: %s", utils.Err(err)))
 
+	ginkgo.By(fmt.Sprintf("Checking if emojivoto is reachable (%s)", c.GetIngressReachability()))
+	err = checkReachability(ctrl)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to reach emojivoto: %s", utils.Err(err)))
-
-	ginkgo.By(fmt.Sprintf("Removing ingress controller in namespace %s", utils.NginxNs))
-	_, err = h.Kubectl("", "delete", "ns", utils.NginxNs)
-
-	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to delete resources in namespace %s", utils.NginxNs))
 }
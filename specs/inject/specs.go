@@ -11,6 +11,7 @@ func RunInjectTests() bool {
 		_, c := utils.GetHelperAndConfig()
 
 		_ = utils.ShouldTestSkip(c.SkipInject(), "Skipping inject tests")
+		utils.RequireControlPlane(false)
 
 		ginkgo.It("can perform manual injection", func() {
 
@@ -27,8 +28,22 @@ func RunInjectTests() bool {
 
 		ginkgo.It("can override pod level proxy config with namespace level config", testInjectAutoNsOverrideAnnotations)
 
-		if clean := c.CleanInject(); clean {
-			ginkgo.It("should delete all resources created during testing", testClean)
-		}
+		ginkgo.It("can report skip reasons for workloads that are not injectable", testInjectSkipReasons)
+
+		ginkgo.It("can skip injection for opted-out workloads in an injected namespace", testInjectOptOut)
+
+		ginkgo.Describe("debug sidecar", func() {
+			ginkgo.It("can be injected with `linkerd inject --enable-debug-sidecar`", testDebugSidecarFlag)
+			ginkgo.It("can be injected with the `config.linkerd.io/enable-debug-sidecar` annotation and capture the pod's traffic", testDebugSidecarAnnotation)
+		})
+
+		ginkgo.Describe("skipped ports", func() {
+			ginkgo.It("can bypass the proxy for the skipped inbound and outbound ports", testSkipPorts)
+		})
+
+		ginkgo.Describe("`linkerd uninject`", func() {
+			ginkgo.It("can restore manifests injected with `linkerd inject`", testUninjectRoundTrip)
+			ginkgo.It("can uninject live injected workloads", testUninjectLive)
+		})
 	})
 }
@@ -1,7 +1,10 @@
 package inject
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/linkerd/linkerd2-conformance/utils"
@@ -9,12 +12,43 @@ import (
 	"github.com/linkerd/linkerd2/testutil"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 var (
-	proxyInjectTestNs           string
-	nsAnnotationsOverrideTestNs string
+	optOutYAMLPath = "testdata/inject/inject_opt_out.yaml"
+
+	// roundTripManifests are injected and uninjected by testUninjectRoundTrip,
+	// and must be restored as is. Manifests with workloads that uninject cannot
+	// fully restore (such as ones with a debug sidecar) must not be listed
+	roundTripManifests = []string{
+		"testdata/inject/inject_test.yaml",
+		"testdata/inject/pod.yaml",
+	}
+
+	// optOutDeploys maps each deployment in optOutYAMLPath
+	// to whether it is expected to receive a proxy
+	optOutDeploys = map[string]bool{
+		"opt-out-enabled-terminus":      true,
+		"opt-out-disabled-terminus":     false,
+		"opt-out-host-network-terminus": false,
+	}
+)
+
+const (
+	// capturedPackets is the number of packets the debug sidecar must capture
+	capturedPackets = 5
+
+	skipPortsServer = "skip-ports-server"
+	skipPortsClient = "skip-ports-client"
+
+	// ports of skipPortsServer, the latter being skipped by the proxies
+	proxiedPort = 8080
+	skippedPort = 9090
+
+	// skipPortsTapTimeout is how long tap events are waited for
+	skipPortsTapTimeout = 30 * time.Second
 )
 
 func testInjectManual(withParams bool) {
@@ -55,27 +89,31 @@ func testInjectManual(withParams bool) {
 		for _, param := range params {
 			cmd = append(cmd, param)
 		}
-		golden = "inject/inject_params.golden"
+		golden = "inject_params.golden"
 	} else {
-		golden = "inject/inject_default.golden"
+		golden = "inject_default.golden"
 	}
-	cmd = append(cmd, injectYAMLPath)
+	cmd = append(cmd, "-")
+
+	// the image config is not applied, as the output is compared with the golden files
+	injectYAML, err := utils.RenderTemplate(injectYAMLPath, utils.GetTemplateValues())
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 
 	ginkgo.By(fmt.Sprintf("Running `linkerd inject` against %s", injectYAMLPath))
-	out, stderr, err := h.LinkerdRun(cmd...)
+	out, stderr, err := h.PipeToLinkerdRun(injectYAML, cmd...)
 
 	gomega.Expect(err).Should(gomega.BeNil(), stderr)
 
 	ginkgo.By("Validating injected output")
-	err = testutil.ValidateInject(out, golden, h)
-	gomega.Expect(err).To(gomega.BeNil())
+	err = utils.ValidateInjectGolden(h, out, "inject", golden)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 }
 
 func testProxyInjection() {
 	h, _ := utils.GetHelperAndConfig()
 
 	ginkgo.By("Reading pod YAML")
-	podYAML, err := testutil.ReadFile("testdata/inject/pod.yaml")
+	podYAML, err := utils.RenderManifest("testdata/inject/pod.yaml")
 
 	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 
@@ -85,12 +123,17 @@ func testProxyInjection() {
 		k8s.ProxyInjectAnnotation: k8s.ProxyInjectEnabled,
 	}
 
-	proxyInjectTestNs = h.GetTestNamespace(injectNs)
+	proxyInjectTestNs := utils.GetSpecNamespace(injectNs)
 	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", proxyInjectTestNs))
 	err = h.CreateDataPlaneNamespaceIfNotExists(proxyInjectTestNs, nsAnnotations)
 
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", proxyInjectTestNs, utils.Err(err)))
 
+	utils.TrackNamespace(proxyInjectTestNs)
+
+	err = utils.CopyImagePullSecrets(proxyInjectTestNs)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
 	ginkgo.By(fmt.Sprintf("Creating test pod in namespace %s", proxyInjectTestNs))
 	o, err := h.Kubectl(podYAML, "-n", proxyInjectTestNs, "create", "-f", "-")
 
@@ -117,6 +160,7 @@ func testProxyInjection() {
 		return nil
 	})
 	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+	utils.RunProxyCheck(h, proxyInjectTestNs)
 }
 
 func testInjectAutoNsOverrideAnnotations() {
@@ -124,7 +168,7 @@ func testInjectAutoNsOverrideAnnotations() {
 	h, _ := utils.GetHelperAndConfig()
 
 	ginkgo.By("Reading pod YAML")
-	injectYAML, err := testutil.ReadFile("testdata/inject/inject_test.yaml")
+	injectYAML, err := utils.RenderManifest("testdata/inject/inject_test.yaml")
 
 	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 
@@ -139,13 +183,18 @@ func testInjectAutoNsOverrideAnnotations() {
 		k8s.ProxyMemoryRequestAnnotation: nsProxyMemReq,
 	}
 
-	nsAnnotationsOverrideTestNs = h.GetTestNamespace(injectNs)
+	nsAnnotationsOverrideTestNs := utils.GetSpecNamespace(injectNs)
 
-	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", proxyInjectTestNs))
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", nsAnnotationsOverrideTestNs))
 	err = h.CreateDataPlaneNamespaceIfNotExists(nsAnnotationsOverrideTestNs, nsAnnotations)
 
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", nsAnnotationsOverrideTestNs, utils.Err(err)))
 
+	utils.TrackNamespace(nsAnnotationsOverrideTestNs)
+
+	err = utils.CopyImagePullSecrets(nsAnnotationsOverrideTestNs)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
 	podProxyCPUReq := "600m"
 	podAnnotations := map[string]string{
 		k8s.ProxyCPURequestAnnotation: podProxyCPUReq,
@@ -179,27 +228,569 @@ func testInjectAutoNsOverrideAnnotations() {
 	gomega.Expect(proxyContainer.Resources.Requests["cpu"]).Should(gomega.Equal(resource.MustParse(podProxyCPUReq)), "proxy cpu resource request failed to match with namespace level override")
 }
 
-func testClean() {
+func testInjectSkipReasons() {
+	h, _ := utils.GetHelperAndConfig()
+
+	optOutYAML, err := utils.RenderManifest(optOutYAMLPath)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	// workloads using the host network cannot be injected, and make `linkerd inject`
+	// fail rather than being skipped, hence they are injected separately
+	hostNetworkYAML, otherYAML := splitHostNetworkDocs(optOutYAML)
+
+	ginkgo.By(fmt.Sprintf("Running `linkerd inject` against %s", optOutYAMLPath))
+	_, report, err := h.PipeToLinkerdRun(otherYAML, "inject", "--disable-identity", "--ignore-cluster", "-")
+	gomega.Expect(err).Should(gomega.BeNil(), report)
+
+	expectedLines := []string{
+		fmt.Sprintf("\"%s: %s\" annotation set on deployment/opt-out-disabled-terminus", k8s.ProxyInjectAnnotation, k8s.ProxyInjectDisabled),
+		"deployment \"opt-out-enabled-terminus\" injected",
+		"deployment \"opt-out-disabled-terminus\" skipped",
+		"service \"opt-out-enabled-terminus\" skipped",
+	}
+
+	ginkgo.By("Validating skip reasons in the inject report")
+	for _, line := range expectedLines {
+		gomega.Expect(report).Should(gomega.ContainSubstring(line), fmt.Sprintf("inject report is missing %q:\n%s", line, report))
+	}
+
+	ginkgo.By("Checking that `linkerd inject` rejects workloads using the host network")
+	_, stderr, err := h.PipeToLinkerdRun(hostNetworkYAML, "inject", "--disable-identity", "--ignore-cluster", "-")
+	gomega.Expect(err).ShouldNot(gomega.BeNil(), "`linkerd inject` did not fail for deployment/opt-out-host-network-terminus")
+	gomega.Expect(stderr).Should(gomega.ContainSubstring("failed to inject deployment/opt-out-host-network-terminus: hostNetwork is enabled"))
+}
+
+// splitHostNetworkDocs splits a multi-document manifest into the
+// documents of the workloads using the host network, and the other ones
+func splitHostNetworkDocs(manifest string) (string, string) {
+	var hostNetwork, other []string
+	for _, doc := range strings.Split(manifest, "\n---\n") {
+		if strings.Contains(doc, "hostNetwork: true") {
+			hostNetwork = append(hostNetwork, doc)
+		} else {
+			other = append(other, doc)
+		}
+	}
+	return strings.Join(hostNetwork, "\n---\n"), strings.Join(other, "\n---\n")
+}
+
+func testInjectOptOut() {
 	h, _ := utils.GetHelperAndConfig()
 
-	namespaces := []string{
-		proxyInjectTestNs,
-		nsAnnotationsOverrideTestNs,
+	ginkgo.By("Reading opt-out YAML")
+	optOutYAML, err := utils.RenderManifest(optOutYAMLPath)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	nsAnnotations := map[string]string{
+		k8s.ProxyInjectAnnotation: k8s.ProxyInjectEnabled,
 	}
 
-	for _, ns := range namespaces {
-		ginkgo.By(fmt.Sprintf("Gathering manifests for namespace/%s", ns))
-		out, err := h.Kubectl("", "-n", ns, "get", "all", "-o", "yaml")
+	optOutTestNs := utils.GetSpecNamespace("inject-opt-out-test")
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", optOutTestNs))
+	err = h.CreateDataPlaneNamespaceIfNotExists(optOutTestNs, nsAnnotations)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", optOutTestNs, utils.Err(err)))
+
+	utils.TrackNamespace(optOutTestNs)
+
+	err = utils.CopyImagePullSecrets(optOutTestNs)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 
+	ginkgo.By(fmt.Sprintf("Applying opt-out YAML to namespace %s", optOutTestNs))
+	o, err := h.Kubectl(optOutYAML, "-n", optOutTestNs, "create", "-f", "-")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create resources in namespace %s: %s: %s", optOutTestNs, utils.Err(err), o))
+
+	for deploy := range optOutDeploys {
+		ginkgo.By(fmt.Sprintf("Waiting for deploy/%s to be available", deploy))
+		o, err = h.Kubectl("", "--namespace", optOutTestNs, "wait", "--for=condition=available", "--timeout=120s", "deploy/"+deploy)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to wait for deploy/%s in namespace %s: %s: %s", deploy, optOutTestNs, utils.Err(err), o))
+	}
+
+	ginkgo.By("Checking that only the expected pods have a proxy container")
+	pods, err := h.GetPods(optOutTestNs, map[string]string{})
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods in namespace %s: %s", optOutTestNs, utils.Err(err)))
+
+	for _, pod := range pods {
+		injected, ok := optOutDeploys[pod.GetLabels()["app"]]
+		gomega.Expect(ok).Should(gomega.BeTrue(), fmt.Sprintf("found unexpected pod/%s in namespace %s", pod.GetName(), optOutTestNs))
+
+		proxyContainer := testutil.GetProxyContainer(pod.Spec.Containers)
+		if injected {
+			gomega.Expect(proxyContainer).ShouldNot(gomega.BeNil(), fmt.Sprintf("expected pod/%s to be injected", pod.GetName()))
+		} else {
+			gomega.Expect(proxyContainer).Should(gomega.BeNil(), fmt.Sprintf("expected pod/%s to not be injected", pod.GetName()))
+		}
+	}
+}
+
+func testUninjectRoundTrip() {
+	h, _ := utils.GetHelperAndConfig()
+
+	for _, manifest := range roundTripManifests {
+		original, err := utils.RenderManifest(manifest)
 		gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 
-		ginkgo.By(fmt.Sprintf("Deleting resources in namespace/%s", ns))
-		_, err = h.Kubectl(out, "delete", "-f", "-")
+		ginkgo.By(fmt.Sprintf("Running `linkerd inject --manual` against %s", manifest))
+		injected, stderr, err := h.PipeToLinkerdRun(original, "inject", "--manual", "-")
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to inject %s: %s", manifest, stderr))
 
-		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("could not delete resources in namespace/%s: %s", ns, utils.Err(err)))
+		ginkgo.By(fmt.Sprintf("Running `linkerd uninject` against the injected %s", manifest))
+		uninjected, stderr, err := h.PipeToLinkerdRun(injected, "uninject", "-")
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to uninject %s: %s", manifest, stderr))
 
-		_, err = h.Kubectl("", "delete", "ns", ns)
+		ginkgo.By("Comparing uninjected output with the original manifest")
+		equal, err := utils.YAMLEqual(original, uninjected)
+		gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+		gomega.Expect(equal).Should(gomega.BeTrue(), fmt.Sprintf("uninjected output does not match %s\nExpected:\n%s\nActual:\n%s", manifest, original, uninjected))
+	}
+}
+
+func testUninjectLive() {
+	h, c := utils.GetHelperAndConfig()
+
+	deployName := "inject-test-terminus"
+
+	ginkgo.By("Reading inject test YAML")
+	injectYAML, err := utils.RenderManifest("testdata/inject/inject_test.yaml")
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	uninjectTestNs := utils.GetSpecNamespace("uninject-test")
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", uninjectTestNs))
+	err = h.CreateDataPlaneNamespaceIfNotExists(uninjectTestNs, nil)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", uninjectTestNs, utils.Err(err)))
+
+	utils.TrackNamespace(uninjectTestNs)
+
+	err = utils.CopyImagePullSecrets(uninjectTestNs)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By("Running `linkerd inject --manual` against inject test YAML")
+	cmd := append([]string{"inject", "--manual"}, c.GetInjectImageFlags()...)
+	out, stderr, err := h.PipeToLinkerdRun(injectYAML, append(cmd, "-")...)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to inject: %s", stderr))
+
+	ginkgo.By(fmt.Sprintf("Applying injected YAML to namespace %s", uninjectTestNs))
+	o, err := h.KubectlApply(out, uninjectTestNs)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply injected resources: %s: %s", utils.Err(err), o))
+
+	err = utils.CheckProxyContainer(deployName, uninjectTestNs)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
 
-		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("could not delete namespace %s: %s", ns, utils.Err(err)))
+	ginkgo.By(fmt.Sprintf("Fetching live manifest for deploy/%s", deployName))
+	out, err = h.Kubectl("", "-n", uninjectTestNs, "get", "deploy", deployName, "-o", "yaml")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get manifest for deploy/%s: %s", deployName, utils.Err(err)))
+
+	ginkgo.By("Running `linkerd uninject` against the live manifest")
+	out, stderr, err = h.PipeToLinkerdRun(out, "uninject", "-")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to uninject: %s", stderr))
+	gomega.Expect(stderr).Should(gomega.ContainSubstring(fmt.Sprintf("deployment \"%s\" uninjected", deployName)), fmt.Sprintf("unexpected uninject report: %s", stderr))
+
+	ginkgo.By("Applying uninjected manifest")
+	o, err = h.KubectlApply(out, uninjectTestNs)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply uninjected resources: %s: %s", utils.Err(err), o))
+
+	o, err = h.Kubectl("", "-n", uninjectTestNs, "rollout", "status", "--timeout=120s", "deploy/"+deployName)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to wait for rollout of deploy/%s: %s: %s", deployName, utils.Err(err), o))
+
+	ginkgo.By("Checking that the proxy container has been removed")
+	err = h.RetryFor(time.Minute*3, func() error {
+		pods, err := h.GetPodsForDeployment(uninjectTestNs, deployName)
+		if err != nil {
+			return fmt.Errorf("failed to get pods for deploy/%s: %s", deployName, err.Error())
+		}
+
+		for _, pod := range pods {
+			if testutil.GetProxyContainer(pod.Spec.Containers) != nil {
+				return fmt.Errorf("pod/%s still has a proxy container", pod.GetName())
+			}
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+// annotateDebugImage annotates the pods of the given deployment with the
+// debug image returned by `GetDebugImage`, if any. As the pod annotations
+// are replaced, the existing ones must be passed as podAnnotations
+func annotateDebugImage(manifest, deploy string, manual bool, podAnnotations map[string]string) string {
+	_, c := utils.GetHelperAndConfig()
+
+	debugImage := c.GetDebugImage(manual)
+	if debugImage == "" {
+		return manifest
+	}
+
+	annotations := map[string]string{
+		k8s.DebugImageAnnotation: debugImage,
+	}
+	for k, v := range podAnnotations {
+		annotations[k] = v
+	}
+
+	ginkgo.By(fmt.Sprintf("Annotating deploy/%s with %s", deploy, k8s.DebugImageAnnotation))
+	patched, err := testutil.PatchDeploy(manifest, deploy, annotations)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to patch deploy/%s: %s", deploy, utils.Err(err)))
+	return patched
+}
+
+// getExpectedDebugImage returns the image of the debug sidecar, which is
+// the one returned by `GetDebugImage` if any, and the one configured in
+// the control plane by `linkerd install` otherwise
+func getExpectedDebugImage(manual bool) string {
+	h, c := utils.GetHelperAndConfig()
+
+	out, err := h.Kubectl("", "-n", h.GetLinkerdNamespace(), "get", "cm", k8s.ConfigConfigMapName, "-o", "jsonpath={.data.proxy}")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get the proxy config: %s: %s", utils.Err(err), out))
+
+	var config struct {
+		DebugImage struct {
+			ImageName string `json:"imageName"`
+		} `json:"debugImage"`
+		DebugImageVersion string `json:"debugImageVersion"`
+	}
+	err = json.Unmarshal([]byte(out), &config)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to parse the proxy config: %s", utils.Err(err)))
+
+	name, version := c.GetDebugImage(manual), config.DebugImageVersion
+	if name == "" {
+		name = config.DebugImage.ImageName
+	}
+
+	if name == "" {
+		name = k8s.DebugSidecarImage
+	}
+
+	if version == "" {
+		version = h.GetVersion()
+	}
+	return fmt.Sprintf("%s:%s", name, version)
+}
+
+// checkDebugContainer checks that the pods of the given deployment have
+// a debug sidecar with the expected image, and returns one of them
+func checkDebugContainer(ns, deploy string, manual bool) corev1.Pod {
+	h, _ := utils.GetHelperAndConfig()
+
+	o, err := h.Kubectl("", "-n", ns, "rollout", "status", "--timeout=120s", "deploy/"+deploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to wait for rollout of deploy/%s: %s: %s", deploy, utils.Err(err), o))
+
+	pods, err := h.GetPodsForDeployment(ns, deploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods for deploy/%s: %s", deploy, utils.Err(err)))
+	gomega.Expect(pods).ShouldNot(gomega.BeEmpty(), fmt.Sprintf("no pods found for deploy/%s", deploy))
+
+	expectedImage := getExpectedDebugImage(manual)
+
+	ginkgo.By(fmt.Sprintf("Checking for the %s container in the pods of deploy/%s", k8s.DebugSidecarName, deploy))
+	for _, pod := range pods {
+		var debug *corev1.Container
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name == k8s.DebugSidecarName {
+				debug = &pod.Spec.Containers[i]
+			}
+		}
+
+		gomega.Expect(debug).ShouldNot(gomega.BeNil(), fmt.Sprintf("pod/%s has no %s container", pod.GetName(), k8s.DebugSidecarName))
+		gomega.Expect(debug.Image).Should(gomega.Equal(expectedImage), fmt.Sprintf("unexpected image for the %s container of pod/%s", k8s.DebugSidecarName, pod.GetName()))
+		gomega.Expect(testutil.GetProxyContainer(pod.Spec.Containers)).ShouldNot(gomega.BeNil(), fmt.Sprintf("pod/%s has no proxy container", pod.GetName()))
+	}
+	return pods[0]
+}
+
+func testDebugSidecarFlag() {
+	h, c := utils.GetHelperAndConfig()
+	deploy := "inject-test-terminus"
+
+	injectYAML, err := utils.RenderManifest("testdata/inject/inject_test.yaml")
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	injectYAML = annotateDebugImage(injectYAML, deploy, true, nil)
+
+	ns := utils.GetSpecNamespace("debug-sidecar-manual")
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", ns))
+	err = h.CreateDataPlaneNamespaceIfNotExists(ns, nil)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", ns, utils.Err(err)))
+
+	utils.TrackNamespace(ns)
+
+	err = utils.CopyImagePullSecrets(ns)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By("Running `linkerd inject --manual --enable-debug-sidecar` against inject test YAML")
+	cmd := append([]string{"inject", "--manual", "--enable-debug-sidecar"}, c.GetInjectImageFlags()...)
+	out, stderr, err := h.PipeToLinkerdRun(injectYAML, append(cmd, "-")...)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to inject: %s", stderr))
+
+	ginkgo.By(fmt.Sprintf("Applying injected YAML to namespace %s", ns))
+	o, err := h.KubectlApply(out, ns)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply injected resources: %s: %s", utils.Err(err), o))
+
+	_ = checkDebugContainer(ns, deploy, true)
+}
+
+func testDebugSidecarAnnotation() {
+	h, _ := utils.GetHelperAndConfig()
+	deploy := "debug-sidecar-terminus"
+
+	debugYAML, err := utils.RenderManifest("testdata/debug/debug_sidecar.yaml")
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	debugYAML = annotateDebugImage(debugYAML, deploy, false, map[string]string{
+		k8s.ProxyEnableDebugAnnotation: "true",
+	})
+
+	ns := utils.GetSpecNamespace("debug-sidecar-auto")
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", ns))
+	err = h.CreateDataPlaneNamespaceIfNotExists(ns, map[string]string{
+		k8s.ProxyInjectAnnotation: k8s.ProxyInjectEnabled,
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", ns, utils.Err(err)))
+
+	utils.TrackNamespace(ns)
+
+	err = utils.CopyImagePullSecrets(ns)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Applying deploy/%s annotated with %s to namespace %s", deploy, k8s.ProxyEnableDebugAnnotation, ns))
+	o, err := h.KubectlApply(debugYAML, ns)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply resources: %s: %s", utils.Err(err), o))
+
+	pod := checkDebugContainer(ns, deploy, false)
+
+	adminPort, err := utils.GetProxyAdminPort(pod)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	// the kubelet probes the proxy admin port every few seconds, hence
+	// the debug sidecar must observe this traffic from the pod's network namespace
+	ginkgo.By(fmt.Sprintf("Capturing traffic to port %d with the %s container of pod/%s", adminPort, k8s.DebugSidecarName, pod.GetName()))
+	o, err = h.Kubectl("", "-n", ns, "exec", pod.GetName(), "-c", k8s.DebugSidecarName, "--",
+		"timeout", "60", "tcpdump", "-i", "any", "-nn", "-c", strconv.Itoa(capturedPackets), "tcp", "port", strconv.Itoa(adminPort))
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to capture %d packets with tcpdump: %s: %s", capturedPackets, utils.Err(err), o))
+	gomega.Expect(o).Should(gomega.ContainSubstring(fmt.Sprintf("%d packets captured", capturedPackets)), fmt.Sprintf("unexpected tcpdump output: %s", o))
+}
+
+// isCNIEnabled determines if the control plane was installed with `--linkerd-cni-enabled`,
+// in which case the iptables rules of the injected pods are configured by the linkerd-cni plugin
+func isCNIEnabled() bool {
+	h, _ := utils.GetHelperAndConfig()
+
+	out, err := h.Kubectl("", "-n", h.GetLinkerdNamespace(), "get", "cm", k8s.ConfigConfigMapName, "-o", "jsonpath={.data.global}")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get the global config: %s: %s", utils.Err(err), out))
+
+	var config struct {
+		CNIEnabled bool `json:"cniEnabled"`
+	}
+	err = json.Unmarshal([]byte(out), &config)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to parse the global config: %s", utils.Err(err)))
+	return config.CNIEnabled
+}
+
+// getInitArg returns the value of the given flag of the proxy-init container
+func getInitArg(init corev1.Container, flag string) string {
+	for i, arg := range init.Args {
+		if arg == flag && i+1 < len(init.Args) {
+			return init.Args[i+1]
+		}
+	}
+	return ""
+}
+
+// checkSkippedPortsConfig checks that the iptables rules of the pod skip
+// the proxy for skippedPort, in the given direction ("inbound" or "outbound")
+func checkSkippedPortsConfig(pod corev1.Pod, direction string, cni bool) {
+	annotation := k8s.ProxyIgnoreInboundPortsAnnotation
+	if direction == "outbound" {
+		annotation = k8s.ProxyIgnoreOutboundPortsAnnotation
+	}
+	gomega.Expect(pod.GetAnnotations()[annotation]).Should(gomega.Equal(strconv.Itoa(skippedPort)), fmt.Sprintf("unexpected %s annotation on pod/%s", annotation, pod.GetName()))
+
+	var init *corev1.Container
+	for i := range pod.Spec.InitContainers {
+		if pod.Spec.InitContainers[i].Name == k8s.InitContainerName {
+			init = &pod.Spec.InitContainers[i]
+		}
+	}
+
+	// with the linkerd-cni plugin, the annotation is read by the plugin itself
+	if cni {
+		gomega.Expect(init).Should(gomega.BeNil(), fmt.Sprintf("pod/%s has a %s container, while the linkerd-cni plugin is enabled", pod.GetName(), k8s.InitContainerName))
+		return
+	}
+
+	gomega.Expect(init).ShouldNot(gomega.BeNil(), fmt.Sprintf("pod/%s has no %s container", pod.GetName(), k8s.InitContainerName))
+
+	flag := fmt.Sprintf("--%s-ports-to-ignore", direction)
+	ports := strings.Split(getInitArg(*init, flag), ",")
+	gomega.Expect(ports).Should(gomega.ContainElement(strconv.Itoa(skippedPort)), fmt.Sprintf("unexpected %s for the %s container of pod/%s", flag, k8s.InitContainerName, pod.GetName()))
+
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == k8s.InitContainerName {
+			terminated := status.State.Terminated
+			gomega.Expect(terminated).ShouldNot(gomega.BeNil(), fmt.Sprintf("the %s container of pod/%s has not completed", k8s.InitContainerName, pod.GetName()))
+			gomega.Expect(terminated.ExitCode).Should(gomega.BeZero(), fmt.Sprintf("the %s container of pod/%s failed: %s", k8s.InitContainerName, pod.GetName(), terminated.Message))
+		}
+	}
+}
+
+// getProxyRequests returns the number of requests to authority reported by
+// the proxy of the given deployment in the given direction, per value of the tls label
+func getProxyRequests(adminURL, direction, authority string) (map[string]float64, error) {
+	metrics, err := utils.GetProxyMetrics(adminURL, "request_total")
+	if err != nil {
+		return nil, err
+	}
+
+	requests := map[string]float64{}
+	for _, m := range metrics {
+		if m.Labels["direction"] == direction && m.Labels["authority"] == authority {
+			requests[m.Labels["tls"]] += m.Value
+		}
+	}
+	return requests, nil
+}
+
+// tapSkipPorts returns the requests to authority tapped in the given namespace
+func tapSkipPorts(ns, authority string, lines int) ([]string, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	stream, err := h.LinkerdRunStream("tap", "ns/"+ns, "--authority", authority)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Stop()
+
+	events := []string{}
+	out, err := stream.ReadUntil(lines, skipPortsTapTimeout)
+	for _, line := range out {
+		if strings.HasPrefix(line, "req ") {
+			events = append(events, line)
+		}
+	}
+	return events, err
+}
+
+func testSkipPorts() {
+	h, c := utils.GetHelperAndConfig()
+
+	skipYAML, err := utils.RenderManifest("testdata/inject/skip_ports.yaml")
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ns := utils.GetSpecNamespace("skip-ports")
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", ns))
+	err = h.CreateDataPlaneNamespaceIfNotExists(ns, nil)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", ns, utils.Err(err)))
+
+	utils.TrackNamespace(ns)
+
+	err = utils.CopyImagePullSecrets(ns)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	port := strconv.Itoa(skippedPort)
+	ginkgo.By(fmt.Sprintf("Running `linkerd inject --manual --skip-inbound-ports %s --skip-outbound-ports %s` against skip ports YAML", port, port))
+	cmd := append([]string{"inject", "--manual", "--skip-inbound-ports", port, "--skip-outbound-ports", port}, c.GetInjectImageFlags()...)
+	out, stderr, err := h.PipeToLinkerdRun(skipYAML, append(cmd, "-")...)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to inject: %s", stderr))
+
+	ginkgo.By(fmt.Sprintf("Applying injected YAML to namespace %s", ns))
+	o, err := h.KubectlApply(out, ns)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply injected resources: %s: %s", utils.Err(err), o))
+
+	for _, deploy := range []string{skipPortsServer, skipPortsClient} {
+		o, err := h.Kubectl("", "-n", ns, "rollout", "status", "--timeout=120s", "deploy/"+deploy)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to wait for rollout of deploy/%s: %s: %s", deploy, utils.Err(err), o))
+
+		err = utils.CheckProxyContainer(deploy, ns)
+		gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+	}
+
+	cni := isCNIEnabled()
+	mode := k8s.InitContainerName
+	if cni {
+		mode = "linkerd-cni"
+	}
+
+	ginkgo.By(fmt.Sprintf("Checking that the iptables rules configured by %s skip port %s", mode, port))
+	for deploy, direction := range map[string]string{skipPortsServer: "inbound", skipPortsClient: "outbound"} {
+		pods, err := h.GetPodsForDeployment(ns, deploy)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods for deploy/%s: %s", deploy, utils.Err(err)))
+		gomega.Expect(pods).ShouldNot(gomega.BeEmpty(), fmt.Sprintf("no pods found for deploy/%s", deploy))
+
+		for _, pod := range pods {
+			checkSkippedPortsConfig(pod, direction, cni)
+		}
+	}
+
+	if cni {
+		o, err := h.Kubectl("", "-n", utils.CNINamespace, "rollout", "status", "--timeout=60s", "daemonset/"+utils.CNIDaemonSet)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("the linkerd-cni plugin is not ready: %s: %s", utils.Err(err), o))
+	}
+
+	for p, text := range map[int]string{proxiedPort: "proxied", skippedPort: "skipped"} {
+		url := fmt.Sprintf("http://%s:%d/", skipPortsServer, p)
+
+		ginkgo.By(fmt.Sprintf("Sending a request to %s from deploy/%s", url, skipPortsClient))
+		err := h.RetryFor(time.Minute, func() error {
+			o, err := h.Kubectl("", "-n", ns, "exec", "deploy/"+skipPortsClient, "-c", "client", "--",
+				"curl", "-s", "--max-time", "5", url)
+			if err != nil {
+				return fmt.Errorf("request failed: %s: %s", err, o)
+			}
+
+			if !strings.Contains(o, text) {
+				return fmt.Errorf("unexpected response: %s", o)
+			}
+			return nil
+		})
+		gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+	}
+
+	proxied := fmt.Sprintf("%s:%d", skipPortsServer, proxiedPort)
+	skipped := fmt.Sprintf("%s:%d", skipPortsServer, skippedPort)
+
+	for deploy, direction := range map[string]string{skipPortsServer: "inbound", skipPortsClient: "outbound"} {
+		adminURL := utils.GetProxyAdminURL(ns, deploy)
+
+		ginkgo.By(fmt.Sprintf("Checking that the %s requests to %s are proxied by deploy/%s", direction, proxied, deploy))
+		err := h.RetryFor(time.Minute, func() error {
+			requests, err := getProxyRequests(adminURL, direction, proxied)
+			if err != nil {
+				return err
+			}
+
+			if requests["true"] == 0 {
+				return fmt.Errorf("no mTLS %s requests to %s reported by deploy/%s: %v", direction, proxied, deploy, requests)
+			}
+			return nil
+		})
+		gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+		// the client has been sending requests to both ports since it was
+		// installed, none of which must have gone through the proxies
+		ginkgo.By(fmt.Sprintf("Checking that the %s requests to %s bypass the proxy of deploy/%s", direction, skipped, deploy))
+		requests, err := getProxyRequests(adminURL, direction, skipped)
+		gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+		gomega.Expect(requests).Should(gomega.BeEmpty(), fmt.Sprintf("%s requests to %s were reported by deploy/%s", direction, skipped, deploy))
+	}
+
+	ginkgo.By(fmt.Sprintf("Tapping the requests to %s", proxied))
+	events, err := tapSkipPorts(ns, proxied, 20)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to tap namespace %s: %s", ns, utils.Err(err)))
+
+	directions := map[string]bool{}
+	for _, event := range events {
+		for _, field := range strings.Fields(event) {
+			if strings.HasPrefix(field, "proxy=") {
+				directions[strings.TrimPrefix(field, "proxy=")] = true
+			}
+		}
+	}
+	gomega.Expect(directions).Should(gomega.HaveKey("in"), fmt.Sprintf("no inbound requests to %s tapped", proxied))
+	gomega.Expect(directions).Should(gomega.HaveKey("out"), fmt.Sprintf("no outbound requests to %s tapped", proxied))
+
+	// tap times out, as no events are received. Any other error means
+	// that the requests could not be tapped at all
+	ginkgo.By(fmt.Sprintf("Checking that the requests to %s are not tapped", skipped))
+	events, err = tapSkipPorts(ns, skipped, 1)
+	if !utils.IsStreamTimeout(err) {
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to tap namespace %s: %s", ns, utils.Err(err)))
 	}
+	gomega.Expect(events).Should(gomega.BeEmpty(), fmt.Sprintf("requests to %s were tapped", skipped))
 }
@@ -1,6 +1,8 @@
 package lifecycle
 
 import (
+	"fmt"
+
 	"github.com/linkerd/linkerd2-conformance/utils"
 	"github.com/onsi/ginkgo"
 )
@@ -45,3 +47,25 @@ func RunUninstallTest() bool {
 		})
 	})
 }
+
+// RunLifecycleSetup installs (and upgrades, if required) the control plane outside
+// of It blocks. It is called from SynchronizedBeforeSuite when the specs run in
+// parallel, so that the control plane is ready before any other spec runs
+func RunLifecycleSetup() {
+	h, c := utils.GetHelperAndConfig()
+
+	if err := h.CheckIfNamespaceExists(h.GetLinkerdNamespace()); err == nil {
+		fmt.Printf("linkerd control plane already exists in namespace %s - skipping installation\n", h.GetLinkerdNamespace())
+	} else {
+		// skipping is not allowed outside of It blocks
+		if reason := utils.GetCapabilities().ControlPlaneUnsupportedReason(c.HA()); reason != "" {
+			ginkgo.Fail(fmt.Sprintf("cannot install the control plane: %s", reason))
+		}
+		utils.InstallLinkerdControlPlane(h, c)
+	}
+
+	if h.UpgradeFromVersion() != "" {
+		testUpgradeCLI()
+		testUpgrade()
+	}
+}
@@ -29,14 +29,17 @@ func testUpgradeCLI() {
 }
 
 func testUpgrade() {
-	h, _ := utils.GetHelperAndConfig()
+	h, c := utils.GetHelperAndConfig()
 
-	cmd := "upgrade"
+	cmd := append([]string{"upgrade"}, c.GetInstallImageFlags()...)
 
 	ginkgo.By("Running `linkerd upgrade` command")
-	out, stderr, err := h.LinkerdRun(cmd)
+	out, stderr, err := h.LinkerdRun(cmd...)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("`linkerd upgrade` command failed: %s", stderr))
 
+	out, err = utils.ApplyImageConfig(out)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply image config to control plane manifests: %s", utils.Err(err)))
+
 	_, err = h.Kubectl(out, "apply", "--prune", "-l", "linkerd.io/control-plane-ns="+h.GetLinkerdNamespace(), "-f", "-")
 
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply manifests: %s", utils.Err(err)))
@@ -0,0 +1,27 @@
+package resilience
+
+import (
+	"fmt"
+
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+)
+
+// RunResilienceTests runs the specs for control plane outages
+func RunResilienceTests() bool {
+	return ginkgo.Describe("control plane resilience: ", func() {
+		_, c := utils.GetHelperAndConfig()
+
+		_ = utils.ShouldTestSkip(c.SkipResilience(), "Skipping control plane resilience tests")
+
+		// scaling down control plane components affects all the meshed workloads
+		utils.RequireControlPlane(true)
+
+		for _, o := range getOutages() {
+			o := o
+			ginkgo.It(fmt.Sprintf("can tolerate an outage of %s", o.deploy), func() {
+				testOutage(o)
+			})
+		}
+	})
+}
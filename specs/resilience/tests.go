@@ -0,0 +1,383 @@
+package resilience
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/testutil"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// emojivotoNs returns the namespace in which emojivoto is installed for the resilience tests
+func emojivotoNs() string {
+	return utils.GetSpecNamespace("resilience-emojivoto")
+}
+
+const (
+	probeInterval    = 500 * time.Millisecond
+	probeTimeout     = 5 * time.Second
+	baselineDuration = 10 * time.Second
+
+	workloadDeploy = "inject-test-terminus"
+	webhookConfig  = "linkerd-proxy-injector-webhook-config"
+)
+
+// outage describes how the control plane degrades while one of its components is unavailable.
+// In all cases, the traffic between meshed workloads that are already running must not be affected
+type outage struct {
+	deploy string
+
+	// during is called right after the component is scaled down, with a
+	// namespace in which automatic injection is enabled
+	during func(ns string)
+
+	// degraded is called once the outage has lasted for `testCase.resilience.outageDuration`
+	// and asserts the documented degradation mode of the component
+	degraded func(ns string)
+
+	// recovered is called once the component has been restored
+	recovered func(ns string)
+}
+
+func getOutages() []outage {
+	return []outage{
+		{
+			// proxies keep using their certificates until they expire, while
+			// new proxies cannot become ready as they cannot obtain one
+			deploy:    "linkerd-identity",
+			during:    createWorkload,
+			degraded:  checkWorkloadUnready,
+			recovered: checkWorkloadReady,
+		},
+		{
+			// proxies keep routing requests to the endpoints they already discovered
+			deploy: "linkerd-destination",
+		},
+		{
+			// pods created while the injector is unavailable are either created without
+			// a proxy or rejected, depending on the failure policy of the webhook
+			deploy:    "linkerd-proxy-injector",
+			during:    createWorkload,
+			degraded:  checkWorkloadNotInjected,
+			recovered: checkWorkloadInjectedAfterRestart,
+		},
+		{
+			// metrics are no longer served, while traffic is not affected
+			deploy:    "linkerd-prometheus",
+			degraded:  checkStatUnavailable,
+			recovered: checkStatAvailable,
+		},
+	}
+}
+
+// trafficResult holds the outcome of a single request sent by a trafficProbe
+type trafficResult struct {
+	latency time.Duration
+	err     error
+}
+
+// trafficReport summarizes the results collected by a trafficProbe
+type trafficReport struct {
+	requests int
+	failures int
+	p50      time.Duration
+	p99      time.Duration
+	max      time.Duration
+	lastErr  error
+}
+
+func (r trafficReport) String() string {
+	s := fmt.Sprintf("%d request(s), %d failure(s), p50 %s, p99 %s, max %s", r.requests, r.failures, r.p50, r.p99, r.max)
+	if r.lastErr != nil {
+		s = fmt.Sprintf("%s, last error: %s", s, r.lastErr)
+	}
+	return s
+}
+
+// trafficProbe continuously sends requests to the web service of emojivoto,
+// which in turn calls the other meshed emojivoto services, recording
+// the outcome and latency of each request
+type trafficProbe struct {
+	url     string
+	client  *http.Client
+	stop    chan struct{}
+	done    chan struct{}
+	results []trafficResult
+}
+
+func startTrafficProbe(url string) *trafficProbe {
+	p := &trafficProbe{
+		url:    url,
+		client: &http.Client{Timeout: probeTimeout},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.done)
+		for {
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+
+			p.results = append(p.results, p.send())
+			time.Sleep(probeInterval)
+		}
+	}()
+	return p
+}
+
+func (p *trafficProbe) send() trafficResult {
+	start := time.Now()
+	resp, err := p.client.Get(p.url)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("GET %s returned status %d", p.url, resp.StatusCode)
+		}
+	}
+	return trafficResult{latency: time.Since(start), err: err}
+}
+
+func (p *trafficProbe) stopAndReport() trafficReport {
+	close(p.stop)
+	<-p.done
+
+	report := trafficReport{requests: len(p.results)}
+	latencies := []time.Duration{}
+	for _, r := range p.results {
+		if r.err != nil {
+			report.failures++
+			report.lastErr = r.err
+			continue
+		}
+		latencies = append(latencies, r.latency)
+	}
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.p50 = latencies[len(latencies)*50/100]
+		report.p99 = latencies[len(latencies)*99/100]
+		report.max = latencies[len(latencies)-1]
+	}
+	return report
+}
+
+// measureTraffic runs a trafficProbe for the given duration
+func measureTraffic(url string, d time.Duration) trafficReport {
+	p := startTrafficProbe(url)
+	time.Sleep(d)
+	return p.stopAndReport()
+}
+
+func expectNoFailures(phase string, r trafficReport) {
+	fmt.Fprintf(ginkgo.GinkgoWriter, "Traffic %s: %s\n", phase, r)
+	gomega.Expect(r.requests).ShouldNot(gomega.BeZero(), fmt.Sprintf("no requests were sent %s", phase))
+	gomega.Expect(r.failures).Should(gomega.BeZero(), fmt.Sprintf("meshed traffic was affected %s: %s", phase, r))
+}
+
+func createWorkloadNamespace(name string) string {
+	h, _ := utils.GetHelperAndConfig()
+
+	ns := utils.GetSpecNamespace(name)
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", ns))
+	err := h.CreateDataPlaneNamespaceIfNotExists(ns, map[string]string{
+		k8s.ProxyInjectAnnotation: k8s.ProxyInjectEnabled,
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", ns, utils.Err(err)))
+	utils.TrackNamespace(ns)
+
+	err = utils.CopyImagePullSecrets(ns)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+	return ns
+}
+
+func createWorkload(ns string) {
+	h, _ := utils.GetHelperAndConfig()
+
+	manifest, err := utils.RenderManifest("testdata/inject/inject_test.yaml")
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Creating deploy/%s in namespace %s", workloadDeploy, ns))
+	out, err := h.KubectlApply(manifest, ns)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create deploy/%s: %s: %s", workloadDeploy, utils.Err(err), out))
+}
+
+func getReadyReplicas(ns string) string {
+	h, _ := utils.GetHelperAndConfig()
+
+	out, err := h.Kubectl("", "-n", ns, "get", "deploy", workloadDeploy, "-o", "jsonpath={.status.readyReplicas}")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get deploy/%s: %s: %s", workloadDeploy, utils.Err(err), out))
+	return strings.TrimSpace(out)
+}
+
+func checkWorkloadReady(ns string) {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Checking that deploy/%s becomes ready", workloadDeploy))
+	out, err := h.Kubectl("", "-n", ns, "rollout", "status", "--timeout=180s", "deploy/"+workloadDeploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("deploy/%s did not become ready: %s: %s", workloadDeploy, utils.Err(err), out))
+
+	err = utils.CheckProxyContainer(workloadDeploy, ns)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func checkWorkloadUnready(ns string) {
+	ginkgo.By(fmt.Sprintf("Checking that deploy/%s cannot become ready without an identity", workloadDeploy))
+	err := utils.CheckProxyContainer(workloadDeploy, ns)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ready := getReadyReplicas(ns)
+	gomega.Expect(ready == "" || ready == "0").Should(gomega.BeTrue(),
+		fmt.Sprintf("deploy/%s has %s ready replica(s) while the identity controller is unavailable", workloadDeploy, ready))
+}
+
+func getWebhookFailurePolicy() string {
+	h, _ := utils.GetHelperAndConfig()
+
+	out, err := h.Kubectl("", "get", "mutatingwebhookconfiguration", webhookConfig, "-o", "jsonpath={.webhooks[0].failurePolicy}")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get %s: %s: %s", webhookConfig, utils.Err(err), out))
+	return strings.TrimSpace(out)
+}
+
+func checkWorkloadNotInjected(ns string) {
+	h, _ := utils.GetHelperAndConfig()
+
+	pods, err := h.GetPodsForDeployment(ns, workloadDeploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods for deploy/%s: %s", workloadDeploy, utils.Err(err)))
+
+	if policy := getWebhookFailurePolicy(); policy == "Fail" {
+		ginkgo.By(fmt.Sprintf("Checking that the pods of deploy/%s were rejected", workloadDeploy))
+		gomega.Expect(pods).Should(gomega.BeEmpty(), fmt.Sprintf("pods of deploy/%s were created while the webhook is unavailable", workloadDeploy))
+		return
+	}
+
+	ginkgo.By(fmt.Sprintf("Checking that the pods of deploy/%s were created without a proxy", workloadDeploy))
+	gomega.Expect(pods).ShouldNot(gomega.BeEmpty(), fmt.Sprintf("no pods found for deploy/%s", workloadDeploy))
+	for _, pod := range pods {
+		gomega.Expect(testutil.GetProxyContainer(pod.Spec.Containers)).Should(gomega.BeNil(),
+			fmt.Sprintf("pod/%s was injected while the proxy injector is unavailable", pod.GetName()))
+	}
+}
+
+// checkWorkloadInjectedAfterRestart checks that pods are injected once the
+// injector is restored. Pods are not re-created on their own, hence the
+// workload is restarted
+func checkWorkloadInjectedAfterRestart(ns string) {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Restarting deploy/%s", workloadDeploy))
+	out, err := h.Kubectl("", "-n", ns, "rollout", "restart", "deploy/"+workloadDeploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to restart deploy/%s: %s: %s", workloadDeploy, utils.Err(err), out))
+
+	checkWorkloadReady(ns)
+}
+
+func runStat() (string, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	out, stderr, err := h.LinkerdRun("stat", "deploy", "-n", emojivotoNs())
+	if err != nil {
+		return "", fmt.Errorf("`linkerd stat` failed: %s: %s", err, stderr)
+	}
+	return out, nil
+}
+
+func checkStatUnavailable(string) {
+	ginkgo.By("Checking that metrics are not served while Prometheus is unavailable")
+	out, err := runStat()
+	if err == nil {
+		gomega.Expect(out).ShouldNot(gomega.ContainSubstring("web"), "`linkerd stat` returned metrics while Prometheus is unavailable")
+	}
+}
+
+func checkStatAvailable(string) {
+	h, _ := utils.GetHelperAndConfig()
+
+	ginkgo.By("Checking that metrics are served once Prometheus is restored")
+	err := h.RetryFor(2*time.Minute, func() error {
+		out, err := runStat()
+		if err != nil {
+			return err
+		}
+
+		if !strings.Contains(out, "web") {
+			return fmt.Errorf("`linkerd stat` returned no metrics for deploy/web:\n%s", out)
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func testOutage(o outage) {
+	h, c := utils.GetHelperAndConfig()
+	ns := h.GetLinkerdNamespace()
+
+	if _, err := h.Kubectl("", "-n", ns, "get", "deploy", o.deploy); err != nil {
+		ginkgo.Skip(fmt.Sprintf("deploy/%s is not part of the control plane", o.deploy))
+	}
+
+	utils.EnsureEmojivoto(emojivotoNs())
+
+	workloadNs := ""
+	if o.during != nil {
+		workloadNs = createWorkloadNamespace(fmt.Sprintf("resilience-%s", strings.TrimPrefix(o.deploy, "linkerd-")))
+	}
+
+	ginkgo.By("Port-forwarding to deploy/web")
+	url, err := h.URLFor(emojivotoNs(), "web", 8080)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to port-forward to deploy/web: %s", utils.Err(err)))
+	url += "/api/list"
+
+	ginkgo.By("Measuring meshed traffic before the outage")
+	expectNoFailures("before the outage", measureTraffic(url, baselineDuration))
+
+	replicas := utils.GetDeployReplicas(ns, o.deploy)
+
+	ginkgo.By(fmt.Sprintf("Scaling down deploy/%s", o.deploy))
+	err = utils.ScaleDeploy(ns, o.deploy, "0")
+	restored := false
+	restore := func() {
+		if restored {
+			return
+		}
+		restored = true
+
+		ginkgo.By(fmt.Sprintf("Restoring deploy/%s to %s replica(s)", o.deploy, replicas))
+		err := utils.ScaleDeploy(ns, o.deploy, replicas)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to restore deploy/%s: %s", o.deploy, utils.Err(err)))
+	}
+	defer restore()
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to scale down deploy/%s: %s", o.deploy, utils.Err(err)))
+
+	probe := startTrafficProbe(url)
+	start := time.Now()
+	if o.during != nil {
+		o.during(workloadNs)
+	}
+	time.Sleep(c.GetOutageDuration() - time.Since(start))
+
+	if o.degraded != nil {
+		o.degraded(workloadNs)
+	}
+	expectNoFailures(fmt.Sprintf("during the outage of deploy/%s", o.deploy), probe.stopAndReport())
+
+	restore()
+	utils.TestControlPlanePostInstall(h)
+	utils.RunCheck(h, false)
+
+	if o.recovered != nil {
+		o.recovered(workloadNs)
+	}
+
+	ginkgo.By("Measuring meshed traffic after the outage")
+	expectNoFailures("after the outage", measureTraffic(url, baselineDuration))
+}
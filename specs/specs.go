@@ -1,13 +1,23 @@
 package specs
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/linkerd/linkerd2-conformance/specs/addons"
+	"github.com/linkerd/linkerd2-conformance/specs/dashboard"
+	"github.com/linkerd/linkerd2-conformance/specs/dataplane"
+	"github.com/linkerd/linkerd2-conformance/specs/egress"
+	"github.com/linkerd/linkerd2-conformance/specs/ha"
 	"github.com/linkerd/linkerd2-conformance/specs/ingress"
 	"github.com/linkerd/linkerd2-conformance/specs/inject"
 	"github.com/linkerd/linkerd2-conformance/specs/lifecycle"
+	"github.com/linkerd/linkerd2-conformance/specs/resilience"
+	"github.com/linkerd/linkerd2-conformance/specs/trafficsplit"
 	"github.com/linkerd/linkerd2-conformance/utils"
 	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/reporters"
 	"github.com/onsi/gomega"
 )
 
@@ -36,27 +46,98 @@ func runLifecycleTests() bool {
 func runPrimaryTests() bool {
 	h, c := utils.GetHelperAndConfig()
 	return ginkgo.Describe("", func() {
+		// delete the resources created by each spec once it completes,
+		// before the control plane is uninstalled (if required)
+		_ = ginkgo.AfterEach(utils.CleanupSpecResources)
+
 		runBeforeAndAfterEachSetup()
 
 		// add primary tests here
 		_ = inject.RunInjectTests()
 		_ = ingress.RunIngressTests()
+		_ = dataplane.RunDataplaneTests()
+		_ = ha.RunHATests()
+		_ = resilience.RunResilienceTests()
+		_ = addons.RunAddOnTests()
+		_ = dashboard.RunDashboardTests()
+		_ = trafficsplit.RunTrafficSplitTests()
+		_ = egress.RunEgressTests()
 
 		// a separate check for running uninstall must always occur at the end
-		if c.SingleControlPlane() && h.Uninstall() {
+		if c.SingleControlPlane() && h.Uninstall() && !utils.Parallel() {
 			_ = lifecycle.RunUninstallTest()
 		}
 	})
 }
 
+// runParallelLifecycle runs the lifecycle tests when the specs run in parallel.
+// The control plane is installed on the first node before any other spec runs,
+// and uninstalled once all the nodes have run their specs
+func runParallelLifecycle() {
+	h, _ := utils.GetHelperAndConfig()
+
+	// the run ID of the first node is shared with the other ones,
+	// so that their run reports can be merged
+	_ = ginkgo.SynchronizedBeforeSuite(func() []byte {
+		lifecycle.RunLifecycleSetup()
+		return []byte(utils.GetRunID())
+	}, func(runID []byte) {
+		utils.SetRunID(string(runID))
+	})
+
+	_ = ginkgo.SynchronizedAfterSuite(utils.CleanupSuiteResources, func() {
+		if h.Uninstall() {
+			utils.UninstallLinkerdControlPlane(h)
+		}
+	})
+}
+
 func runConformanceTestsCallback() {
-	_ = runLifecycleTests()
+	if !utils.Parallel() {
+		_ = runLifecycleTests()
+	}
 	_ = runPrimaryTests()
 }
 
 func RunConformanceTests(t *testing.T) {
+	_, c := utils.GetHelperAndConfig()
+
+	// the capabilities must be detected before the specs are
+	// declared, as they determine which specs must be skipped
+	caps, err := utils.RunPreflight()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	fmt.Print(caps)
+
+	metadata, err := utils.CollectRunMetadata(caps)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	fmt.Print(metadata)
+
+	if utils.Parallel() {
+		if !c.SingleControlPlane() {
+			t.Fatal("specs cannot run in parallel when 'testCase.lifecycle.reinstall' is set to \"true\"")
+		}
+		runParallelLifecycle()
+	} else {
+		_ = ginkgo.AfterSuite(utils.CleanupSuiteResources)
+	}
+
 	_ = ginkgo.Describe("", runConformanceTestsCallback)
 
+	// the JUnit report is kept when the run report is written,
+	// as done by ginkgo.RunSpecs when `--ginkgo.reportFile` is set
+	specReporters := []ginkgo.Reporter{}
+	if reportFile := config.DefaultReporterConfig.ReportFile; reportFile != "" {
+		specReporters = append(specReporters, reporters.NewJUnitReporter(reportFile))
+	}
+
+	if path := c.GetRunReportPath(); path != "" {
+		specReporters = append(specReporters, utils.NewRunReporter(path, *metadata))
+	}
+
 	gomega.RegisterFailHandler(ginkgo.Fail)
-	ginkgo.RunSpecs(t, "Linkerd2 conformance tests")
+	ginkgo.RunSpecsWithDefaultAndCustomReporters(t, "Linkerd2 conformance tests", specReporters)
 }
@@ -0,0 +1,22 @@
+package trafficsplit
+
+import (
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+)
+
+// RunTrafficSplitTests runs the specs for SMI TrafficSplits
+func RunTrafficSplitTests() bool {
+	return ginkgo.Describe("traffic split: ", func() {
+		_, c := utils.GetHelperAndConfig()
+
+		_ = utils.ShouldTestSkip(c.SkipTrafficSplit(), "Skipping TrafficSplit tests")
+		utils.RequireControlPlane(false)
+
+		ginkgo.It("can split traffic according to the weights of a TrafficSplit", testWeightedSplit)
+
+		ginkgo.It("can update the weights of a TrafficSplit at runtime", testWeightUpdate)
+
+		ginkgo.It("can split traffic to a non-existent backend", testMissingBackend)
+	})
+}
@@ -0,0 +1,317 @@
+package trafficsplit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2-conformance/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+const (
+	applicationManifest = "testdata/trafficsplit/application.yaml"
+	splitManifest       = "testdata/trafficsplit/split.yaml"
+
+	splitName    = "backend-split"
+	clientDeploy = "client"
+
+	// the backend services are named after their deployments
+	v1Deploy   = "backend-v1"
+	v2Deploy   = "backend-v2"
+	missingSvc = "backend-missing"
+
+	// minSamples is the number of requests a distribution is measured over
+	minSamples = 200
+)
+
+var (
+	// appInstalled is set once the application has been installed by the current Ginkgo node
+	appInstalled = false
+
+	appDeploys = []string{v1Deploy, v2Deploy, clientDeploy}
+)
+
+// appNs returns the namespace in which the backends and the client are installed
+func appNs() string {
+	return utils.GetSpecNamespace("trafficsplit")
+}
+
+// backend is a backend of a TrafficSplit
+type backend struct {
+	Service string
+	Weight  string
+}
+
+// splitValues holds the values used for rendering `testdata/trafficsplit/split.yaml`
+type splitValues struct {
+	utils.TemplateValues
+	Namespace string
+	Backends  []backend
+}
+
+// ensureApplication installs and injects the backends and the client,
+// unless it has already been done by the current Ginkgo node
+func ensureApplication() {
+	h, c := utils.GetHelperAndConfig()
+	if appInstalled {
+		return
+	}
+
+	supported, err := utils.SupportsResource(h, "split.smi-spec.io/v1alpha1", "trafficsplits")
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+	if !supported {
+		ginkgo.Skip("Skipping as the TrafficSplit CRD (split.smi-spec.io/v1alpha1) is not installed")
+	}
+
+	ginkgo.By(fmt.Sprintf("Creating data plane namespace %s", appNs()))
+	err = h.CreateDataPlaneNamespaceIfNotExists(appNs(), nil)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to create namespace %s: %s", appNs(), utils.Err(err)))
+
+	// the application is used by all the specs, and is deleted once all of them complete
+	utils.TrackSuiteNamespace(appNs())
+
+	err = utils.CopyImagePullSecrets(appNs())
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	manifest, err := utils.RenderManifest(applicationManifest)
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By("Injecting the backends and the client")
+	cmd := append([]string{"inject", "--manual"}, c.GetInjectImageFlags()...)
+	out, stderr, err := h.PipeToLinkerdRun(manifest, append(cmd, "-")...)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to inject: %s", stderr))
+
+	out, err = h.KubectlApply(out, appNs())
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply injected resources: %s: %s", utils.Err(err), out))
+
+	for _, deploy := range appDeploys {
+		err := h.CheckPods(appNs(), deploy, 1)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to validate pods of deploy/%s: %s", deploy, utils.Err(err)))
+
+		err = h.CheckDeployment(appNs(), deploy, 1)
+		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to validate deploy/%s: %s", deploy, utils.Err(err)))
+	}
+
+	utils.RunProxyCheck(h, appNs())
+	appInstalled = true
+}
+
+// applySplit creates or updates the TrafficSplit of backend-svc with the given backends
+func applySplit(backends ...backend) {
+	h, _ := utils.GetHelperAndConfig()
+
+	manifest, err := utils.RenderTemplate(splitManifest, splitValues{
+		TemplateValues: utils.GetTemplateValues(),
+		Namespace:      appNs(),
+		Backends:       backends,
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+
+	ginkgo.By(fmt.Sprintf("Applying trafficsplit/%s with backends %v", splitName, backends))
+	out, err := h.KubectlApply(manifest, appNs())
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply trafficsplit/%s: %s: %s", splitName, utils.Err(err), out))
+
+	utils.TrackManifest(manifest)
+}
+
+// getResponseCounts returns the number of successful responses received
+// by the client proxy, per destination deployment
+func getResponseCounts(adminURL string) (map[string]float64, error) {
+	metrics, err := utils.GetProxyMetrics(adminURL, "response_total")
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]float64{}
+	for _, m := range metrics {
+		if m.Labels["direction"] == "outbound" && m.Labels["classification"] == "success" {
+			counts[m.Labels["dst_deployment"]] += m.Value
+		}
+	}
+	return counts, nil
+}
+
+// measureSplit returns the share of the successful requests of the client
+// received by each backend, over at least minSamples requests
+func measureSplit(adminURL string) (map[string]float64, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	before, err := getResponseCounts(adminURL)
+	if err != nil {
+		return nil, err
+	}
+
+	deltas := map[string]float64{}
+	err = h.RetryFor(2*time.Minute, func() error {
+		after, err := getResponseCounts(adminURL)
+		if err != nil {
+			return err
+		}
+
+		total := 0.0
+		for _, deploy := range []string{v1Deploy, v2Deploy} {
+			deltas[deploy] = after[deploy] - before[deploy]
+			total += deltas[deploy]
+		}
+
+		if total < minSamples {
+			return fmt.Errorf("received %v successful responses from the backends, waiting for %d", total, minSamples)
+		}
+
+		for deploy := range deltas {
+			deltas[deploy] /= total
+		}
+		return nil
+	})
+	return deltas, err
+}
+
+// expectSplit checks that the share of the requests received by each backend
+// matches the expected one, within the configured tolerance. The distribution
+// is measured again until it matches, as TrafficSplit updates are propagated
+// to the proxies asynchronously
+func expectSplit(expected map[string]float64) {
+	h, c := utils.GetHelperAndConfig()
+	adminURL := utils.GetProxyAdminURL(appNs(), clientDeploy)
+
+	ginkgo.By(fmt.Sprintf("Checking that the backends receive the expected share of requests: %s", formatShares(expected)))
+	err := h.RetryFor(4*time.Minute, func() error {
+		shares, err := measureSplit(adminURL)
+		if err != nil {
+			return err
+		}
+
+		for deploy, share := range expected {
+			if math.Abs(shares[deploy]-share) > c.GetSplitTolerance() {
+				return fmt.Errorf("observed shares %s do not match the expected shares %s (tolerance: %v)", formatShares(shares), formatShares(expected), c.GetSplitTolerance())
+			}
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+// getClientStatusCodes returns the status codes of the responses received by the
+// client, which are logged in the order the requests were sent. Requests which
+// did not receive a response are logged with the status code "000"
+func getClientStatusCodes() ([]string, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	out, err := h.Kubectl("", "-n", appNs(), "logs", "deploy/"+clientDeploy, "-c", clientDeploy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the logs of deploy/%s: %s: %s", clientDeploy, err, out)
+	}
+	return strings.Fields(out), nil
+}
+
+// measureFailures returns the share of the requests of the client
+// which did not succeed, over at least minSamples requests
+func measureFailures() (float64, error) {
+	h, _ := utils.GetHelperAndConfig()
+
+	before, err := getClientStatusCodes()
+	if err != nil {
+		return 0, err
+	}
+
+	share := 0.0
+	err = h.RetryFor(3*time.Minute, func() error {
+		after, err := getClientStatusCodes()
+		if err != nil {
+			return err
+		}
+
+		if len(after) < len(before) {
+			before = after
+			return fmt.Errorf("the logs of deploy/%s were truncated", clientDeploy)
+		}
+
+		codes := after[len(before):]
+		if len(codes) < minSamples {
+			return fmt.Errorf("the client sent %d requests, waiting for %d", len(codes), minSamples)
+		}
+
+		failed := 0
+		for _, code := range codes {
+			if !strings.HasPrefix(code, "2") {
+				failed++
+			}
+		}
+		share = float64(failed) / float64(len(codes))
+		return nil
+	})
+	return share, err
+}
+
+// expectFailures checks that the share of the requests of the client which
+// did not succeed matches the expected one, within the configured tolerance
+func expectFailures(expected float64) {
+	h, c := utils.GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Checking that %.2f of the requests of the client fail", expected))
+	err := h.RetryFor(5*time.Minute, func() error {
+		share, err := measureFailures()
+		if err != nil {
+			return err
+		}
+
+		if math.Abs(share-expected) > c.GetSplitTolerance() {
+			return fmt.Errorf("observed failure share %.2f does not match the expected share %.2f (tolerance: %v)", share, expected, c.GetSplitTolerance())
+		}
+		return nil
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), utils.Err(err))
+}
+
+func formatShares(shares map[string]float64) string {
+	s := []string{}
+	for deploy, share := range shares {
+		s = append(s, fmt.Sprintf("%s=%.2f", deploy, share))
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func testWeightedSplit() {
+	ensureApplication()
+
+	applySplit(backend{v1Deploy, "750m"}, backend{v2Deploy, "250m"})
+	expectSplit(map[string]float64{v1Deploy: 0.75, v2Deploy: 0.25})
+}
+
+func testWeightUpdate() {
+	h, _ := utils.GetHelperAndConfig()
+	ensureApplication()
+
+	applySplit(backend{v1Deploy, "1000m"}, backend{v2Deploy, "0m"})
+	expectSplit(map[string]float64{v1Deploy: 1, v2Deploy: 0})
+
+	applySplit(backend{v1Deploy, "250m"}, backend{v2Deploy, "750m"})
+	expectSplit(map[string]float64{v1Deploy: 0.25, v2Deploy: 0.75})
+
+	// without a TrafficSplit, requests are sent to the pods selected by
+	// backend-svc, which are the ones of backend-v1
+	ginkgo.By(fmt.Sprintf("Deleting trafficsplit/%s", splitName))
+	out, err := h.Kubectl("", "-n", appNs(), "delete", "trafficsplit", splitName)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to delete trafficsplit/%s: %s: %s", splitName, utils.Err(err), out))
+
+	expectSplit(map[string]float64{v1Deploy: 1, v2Deploy: 0})
+}
+
+func testMissingBackend() {
+	ensureApplication()
+
+	// requests routed to the non-existent backend fail, and do not count towards
+	// the measured shares. backend-v1 must keep receiving its share, while
+	// backend-v2 (which is not part of the split) must not receive any request
+	applySplit(backend{v1Deploy, "500m"}, backend{missingSvc, "500m"})
+	expectSplit(map[string]float64{v1Deploy: 1, v2Deploy: 0})
+
+	// the share of the non-existent backend is observed from the client, as requests
+	// which time out while waiting for an endpoint may not be reported by its proxy
+	expectFailures(0.5)
+}
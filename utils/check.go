@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/linkerd/linkerd2/testutil"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// CheckResult is the result of a single check run by `linkerd check`
+type CheckResult string
+
+const (
+	// CheckSuccess is the result of a check that passed
+	CheckSuccess CheckResult = "success"
+
+	// CheckWarning is the result of a check that failed without failing `linkerd check`
+	CheckWarning CheckResult = "warning"
+
+	// CheckError is the result of a check that failed
+	CheckError CheckResult = "error"
+)
+
+// Check holds the result of a single check
+type Check struct {
+	Description string      `json:"description"`
+	Hint        string      `json:"hint,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	Result      CheckResult `json:"result"`
+}
+
+// CheckCategory holds the checks of a category, such as "linkerd-config"
+type CheckCategory struct {
+	Name   string  `json:"categoryName"`
+	Checks []Check `json:"checks"`
+}
+
+// CheckOutput is used for unmarshalling the
+// output from `linkerd check -o json`
+type CheckOutput struct {
+	Success    bool            `json:"success"`
+	Categories []CheckCategory `json:"categories"`
+}
+
+// CheckRef identifies a check by its category and description, as
+// printed by `linkerd check` (e.g. "linkerd-config" and "control plane Namespace exists")
+type CheckRef struct {
+	Category    string `yaml:"category"`
+	Description string `yaml:"description"`
+}
+
+func (r CheckRef) String() string {
+	return fmt.Sprintf("%q in category %q", r.Description, r.Category)
+}
+
+// dataPlaneChecks must pass for every `linkerd check --proxy` run
+var dataPlaneChecks = []CheckRef{
+	{Category: "linkerd-data-plane", Description: "data plane proxies are ready"},
+	{Category: "linkerd-data-plane", Description: "data plane and cli versions match"},
+	{Category: "linkerd-identity-data-plane", Description: "data plane proxies certificate match CA"},
+}
+
+// CheckAssertions holds the assertions applied to the output of a `linkerd check` run,
+// in addition to `linkerd check` itself succeeding
+type CheckAssertions struct {
+	FailOnWarnings  bool       `yaml:"failOnWarnings,omitempty"`
+	AllowedWarnings []CheckRef `yaml:"allowedWarnings,omitempty"` // tolerated when failOnWarnings is set
+	MustPass        []CheckRef `yaml:"mustPass,omitempty"`        // must be run and succeed
+}
+
+// Find returns the check identified by the given reference
+func (o *CheckOutput) Find(ref CheckRef) (Check, bool) {
+	for _, c := range o.Categories {
+		if c.Name != ref.Category {
+			continue
+		}
+
+		for _, check := range c.Checks {
+			if check.Description == ref.Description {
+				return check, true
+			}
+		}
+	}
+	return Check{}, false
+}
+
+func hasRef(refs []CheckRef, category, description string) bool {
+	for _, r := range refs {
+		if r.Category == category && r.Description == description {
+			return true
+		}
+	}
+	return false
+}
+
+// Table returns a table of the checks which did not succeed
+func (o *CheckOutput) Table() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RESULT\tCATEGORY\tCHECK\tERROR\tHINT")
+
+	rows := 0
+	for _, c := range o.Categories {
+		for _, check := range c.Checks {
+			if check.Result == CheckSuccess {
+				continue
+			}
+
+			// errors may span multiple lines, which would break the table
+			errMsg := strings.Join(strings.Fields(check.Error), " ")
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", check.Result, c.Name, check.Description, errMsg, check.Hint)
+			rows++
+		}
+	}
+
+	if rows == 0 {
+		return ""
+	}
+
+	w.Flush()
+	return buf.String()
+}
+
+// Validate checks the output against the given assertions
+func (o *CheckOutput) Validate(a CheckAssertions) error {
+	problems := []string{}
+
+	if !o.Success {
+		problems = append(problems, "`linkerd check` failed")
+	}
+
+	if a.FailOnWarnings {
+		for _, c := range o.Categories {
+			for _, check := range c.Checks {
+				if check.Result == CheckWarning && !hasRef(a.AllowedWarnings, c.Name, check.Description) {
+					problems = append(problems, fmt.Sprintf("check %q in category %q has a warning", check.Description, c.Name))
+				}
+			}
+		}
+	}
+
+	for _, ref := range a.MustPass {
+		check, ok := o.Find(ref)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("check %s was not run", ref))
+			continue
+		}
+
+		if check.Result != CheckSuccess {
+			problems = append(problems, fmt.Sprintf("check %s must pass, got %s", ref, check.Result))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s\n\n%s", strings.Join(problems, "\n"), o.Table())
+}
+
+// RunCheckWithAssertions runs `linkerd check` with the given arguments, validates its
+// output against the given assertions and returns it for further assertions
+func RunCheckWithAssertions(h *testutil.TestHelper, a CheckAssertions, args ...string) *CheckOutput {
+	cmd := append([]string{
+		"check",
+		"-o",
+		"json",
+	}, args...)
+
+	out, stderr, runErr := h.LinkerdRun(cmd...)
+
+	ginkgo.By("Validating `check` output")
+	var checkResult CheckOutput
+	err := json.Unmarshal([]byte(out), &checkResult)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to unmarshal check results JSON: %s\n`linkerd check` error: %s\n%s", Err(err), Err(runErr), stderr))
+
+	if table := checkResult.Table(); table != "" {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "`linkerd %s` reported the following warnings and errors:\n%s", strings.Join(cmd, " "), table)
+	}
+
+	err = checkResult.Validate(a)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("`linkerd %s` did not pass: %s", strings.Join(cmd, " "), Err(err)))
+	return &checkResult
+}
+
+// getWaitArgs returns the `--wait` flag for `linkerd check`, if `check.wait` is set
+func getWaitArgs() []string {
+	_, c := GetHelperAndConfig()
+	if wait := c.GetCheckWait(); wait != "" {
+		return []string{"--wait", wait}
+	}
+	return []string{}
+}
+
+// RunCheck rus `linkerd check`
+func RunCheck(h *testutil.TestHelper, pre bool) {
+	_, c := GetHelperAndConfig()
+	args := getWaitArgs()
+	a := c.GetPostCheckAssertions()
+
+	if pre {
+		args = append(args, "--pre")
+		if c.CNIEnabled() {
+			args = append(args, "--linkerd-cni-enabled")
+		}
+		a = c.GetPreCheckAssertions()
+		ginkgo.By("Running pre-installation checks")
+	} else {
+		ginkgo.By("Running post-installation checks")
+	}
+
+	_ = RunCheckWithAssertions(h, a, args...)
+}
+
+// RunProxyCheck runs `linkerd check --proxy` for the given namespace. Besides the
+// configured assertions, the readiness, version and certificates of the proxies
+// in the namespace must be validated successfully
+func RunProxyCheck(h *testutil.TestHelper, namespace string) {
+	_, c := GetHelperAndConfig()
+	ginkgo.By(fmt.Sprintf("Running data plane checks for namespace %s", namespace))
+
+	a := c.GetProxyCheckAssertions()
+	a.MustPass = append(append([]CheckRef{}, dataPlaneChecks...), a.MustPass...)
+
+	args := append(getWaitArgs(), "--proxy", "--namespace", namespace)
+	_ = RunCheckWithAssertions(h, a, args...)
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/onsi/ginkgo"
+)
+
+// trackedResource is a resource created while testing, which
+// is either a namespace or the manifest that was applied
+type trackedResource struct {
+	owner     string // full text of the spec that created the resource
+	namespace string
+	manifest  string
+}
+
+func (r trackedResource) String() string {
+	if r.namespace != "" {
+		return fmt.Sprintf("namespace/%s", r.namespace)
+	}
+	return "resources applied"
+}
+
+func (r trackedResource) delete() error {
+	h, _ := GetHelperAndConfig()
+
+	var out string
+	var err error
+	if r.namespace != "" {
+		out, err = h.Kubectl("", "delete", "ns", r.namespace, "--ignore-not-found")
+	} else {
+		out, err = h.Kubectl(r.manifest, "delete", "--ignore-not-found", "-f", "-")
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to delete %s created by %q: %s: %s", r, r.owner, err, out)
+	}
+	return nil
+}
+
+var (
+	// specResources are deleted once the spec that created them completes
+	specResources []trackedResource
+
+	// suiteResources are shared by multiple specs, and are
+	// deleted once all the specs have run on the current node
+	suiteResources []trackedResource
+
+	// suiteFailed is set when any of the specs run on the current node fails
+	suiteFailed bool
+)
+
+func newTrackedResource(namespace, manifest string) trackedResource {
+	return trackedResource{
+		owner:     ginkgo.CurrentGinkgoTestDescription().FullTestText,
+		namespace: namespace,
+		manifest:  manifest,
+	}
+}
+
+// TrackNamespace records a namespace to be deleted once the current spec completes
+func TrackNamespace(namespace string) {
+	specResources = append(specResources, newTrackedResource(namespace, ""))
+}
+
+// TrackManifest records the resources of a manifest to be deleted once the current spec completes
+func TrackManifest(manifest string) {
+	specResources = append(specResources, newTrackedResource("", manifest))
+}
+
+// TrackSuiteNamespace records a namespace used by multiple specs
+// (such as emojivoto) to be deleted once all the specs complete
+func TrackSuiteNamespace(namespace string) {
+	for _, r := range suiteResources {
+		if r.namespace == namespace {
+			return
+		}
+	}
+	suiteResources = append(suiteResources, newTrackedResource(namespace, ""))
+}
+
+// deleteTrackedResources deletes the given resources in the reverse order
+// in which they were created, unless they must be kept for inspection
+func deleteTrackedResources(resources []trackedResource, failed bool) {
+	_, c := GetHelperAndConfig()
+
+	if failed && c.ShouldKeepResourcesOnFailure() {
+		for _, r := range resources {
+			fmt.Fprintf(ginkgo.GinkgoWriter, "Keeping %s created by %q for inspection\n", r, r.owner)
+		}
+		return
+	}
+
+	for i := len(resources) - 1; i >= 0; i-- {
+		ginkgo.By(fmt.Sprintf("Cleaning up %s", resources[i]))
+		if err := resources[i].delete(); err != nil {
+			// keep deleting the remaining resources
+			fmt.Fprintln(ginkgo.GinkgoWriter, err.Error())
+		}
+	}
+}
+
+// CleanupSpecResources deletes the resources tracked by the spec that just
+// completed. It is registered as an AfterEach of the top level Describe block,
+// and is also called by RequireControlPlane before releasing the node lock.
+// The resources are only deleted by the first call following the spec
+func CleanupSpecResources() {
+	failed := ginkgo.CurrentGinkgoTestDescription().Failed
+	if failed {
+		suiteFailed = true
+	}
+
+	resources := specResources
+	specResources = nil
+	deleteTrackedResources(resources, failed)
+}
+
+// CleanupSuiteResources deletes the resources shared by multiple specs. It must be called
+// once all the specs have run on the current node, from AfterSuite (or
+// SynchronizedAfterSuite, when running in parallel)
+func CleanupSuiteResources() {
+	resources := suiteResources
+	suiteResources = nil
+	deleteTrackedResources(resources, suiteFailed)
+}
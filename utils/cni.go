@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func renderCNI() string {
+	h, c := GetHelperAndConfig()
+
+	args := []string{"install-cni", "--cni-namespace", CNINamespace}
+	if registry := c.Images.Linkerd.Registry; registry != "" {
+		args = append(args, "--registry", registry)
+	}
+
+	out, stderr, err := h.LinkerdRun(args...)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("`linkerd install-cni` failed: %s", stderr))
+
+	out, err = ApplyImageConfig(out)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply image config to the linkerd-cni manifests: %s", Err(err)))
+	return out
+}
+
+// installCNI installs the linkerd-cni plugin, which configures the iptables
+// rules of the injected pods when `controlPlane.config.cni` is set
+func installCNI() {
+	h, _ := GetHelperAndConfig()
+
+	ginkgo.By(fmt.Sprintf("Installing the linkerd-cni plugin in namespace %s", CNINamespace))
+	out, err := h.KubectlApply(renderCNI(), "")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to install the linkerd-cni plugin: %s: %s", Err(err), out))
+
+	out, err = h.Kubectl("", "-n", CNINamespace, "rollout", "status", "--timeout=180s", "daemonset/"+CNIDaemonSet)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("the linkerd-cni plugin did not become ready: %s: %s", Err(err), out))
+}
+
+// uninstallCNI deletes the resources created by installCNI
+func uninstallCNI() {
+	h, _ := GetHelperAndConfig()
+
+	ginkgo.By("Uninstalling the linkerd-cni plugin")
+	out, err := h.Kubectl(renderCNI(), "delete", "--ignore-not-found", "-f", "-")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to uninstall the linkerd-cni plugin: %s: %s", Err(err), out))
+}
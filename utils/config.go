@@ -1,22 +1,23 @@
 package utils
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/linkerd/linkerd2/testutil"
+	ginkgoconfig "github.com/onsi/ginkgo/config"
 	"gopkg.in/yaml.v2"
 )
 
 // Inject holds the inject test configuration
 type Inject struct {
 	Skip  bool `yaml:"skip,omitempty"`
-	Clean bool `yaml:"clean,omitempty"` // deletes all resources created while testing
+	Clean bool `yaml:"clean,omitempty"` // deprecated: resources are deleted unless `keepResourcesOnFailure` is set
 }
 
 // Lifecycle holds lifecycle test configuration
@@ -29,9 +30,11 @@ type Lifecycle struct {
 
 // ControlPlaneConfig holds the configuration for control plane installation
 type ControlPlaneConfig struct {
-	HA     bool                   `yaml:"ha,omitempty"`
-	Flags  []string               `yaml:"flags,omitempty"`
-	AddOns map[string]interface{} `yaml:"addOns,omitempty"`
+	HA                 bool                   `yaml:"ha,omitempty"`
+	Flags              []string               `yaml:"flags,omitempty"`
+	AddOns             map[string]interface{} `yaml:"addOns,omitempty"`
+	ExternalPrometheus bool                   `yaml:"externalPrometheus,omitempty"` // disables the bundled Prometheus in favour of one deployed by the tests
+	CNI                bool                   `yaml:"cni,omitempty"`                // installs the linkerd-cni plugin instead of using proxy-init
 }
 
 // ControlPlane wraps Namespace and ControlPlaneConfig
@@ -40,9 +43,37 @@ type ControlPlane struct {
 	ControlPlaneConfig `yaml:"config,omitempty"`
 }
 
+// IngressReadiness holds the criteria for considering
+// a user-defined ingress controller to be working
+type IngressReadiness struct {
+	Timeout     string   `yaml:"timeout,omitempty"`
+	Deployments []string `yaml:"deployments,omitempty"` // defaults to all deployments in the controller namespace
+	StatusCode  int      `yaml:"statusCode,omitempty"`
+}
+
+// CustomIngressController holds the configuration of a user-defined ingress controller
+type CustomIngressController struct {
+	Name       string           `yaml:"name"`
+	Manifest   string           `yaml:"manifest"`
+	Namespace  string           `yaml:"namespace"`
+	Deployment string           `yaml:"deployment"`
+	Service    string           `yaml:"service,omitempty"`
+	Port       int              `yaml:"port,omitempty"`
+	Resource   string           `yaml:"resource"` // path to the ingress resource template
+	Host       string           `yaml:"host,omitempty"`
+	Readiness  IngressReadiness `yaml:"readiness,omitempty"`
+
+	// IngressAPIVersions holds the Ingress APIs watched by the controller (defaults to all of them)
+	IngressAPIVersions []string `yaml:"ingressAPIVersions,omitempty"`
+}
+
 // IngressConfig holds the list of ingress controllers
+// and how they must be reached while testing
 type IngressConfig struct {
-	Controllers []string `yaml:"controllers"`
+	Controllers  []string                  `yaml:"controllers"`
+	Custom       []CustomIngressController `yaml:"custom,omitempty"`
+	Reachability string                    `yaml:"reachability,omitempty"`
+	NodeAddress  string                    `yaml:"nodeAddress,omitempty"` // used with the nodePort reachability strategy
 }
 
 // Ingress holds the configuration for ingress test
@@ -51,51 +82,98 @@ type Ingress struct {
 	IngressConfig `yaml:"config,omitempty"`
 }
 
-// TestCase holds configuration of the various test cases
-type TestCase struct {
-	Lifecycle `yaml:"lifecycle,omitempty"`
-	Inject    `yaml:"inject"`
-	Ingress   `yaml:"ingress"`
+// Dataplane holds the configuration for data plane health tests
+type Dataplane struct {
+	Skip bool `yaml:"skip,omitempty"`
 }
 
-// ConformanceTestOptions holds the values fed from the test config file
-type ConformanceTestOptions struct {
-	LinkerdVersion    string `yaml:"linkerdVersion,omitempty"`
-	LinkerdBinaryPath string `yaml:"linkerdBinaryPath,omitempty"`
-	ClusterDomain     string `yaml:"clusterDomain,omitempty"`
-	K8sContext        string `yaml:"k8sContext,omitempty"`
-	ExternalIssuer    bool   `yaml:"externalIssuer,omitempty"`
-	ControlPlane      `yaml:"controlPlane"`
-	TestCase          `yaml:"testCase"`
-	// TODO: Add fields for test specific configurations
-	// TODO: Add fields for Helm tests
+// LinkerdImages holds the image flags passed to `linkerd install`,
+// `linkerd upgrade` and `linkerd inject`, and the debug sidecar image
+type LinkerdImages struct {
+	Registry   string `yaml:"registry,omitempty"`
+	ProxyImage string `yaml:"proxyImage,omitempty"`
+	InitImage  string `yaml:"initImage,omitempty"`
+	DebugImage string `yaml:"debugImage,omitempty"` // only set on the workloads of the debug sidecar tests
 }
 
-func getLatestStableVersion() (string, error) {
+// Images holds the image overrides applied to every manifest used while testing
+type Images struct {
+	Registries           map[string]string `yaml:"registries,omitempty"` // maps a registry (such as docker.io) to a mirror
+	Overrides            map[string]string `yaml:"overrides,omitempty"`  // maps an image to its replacement
+	PullSecrets          []string          `yaml:"pullSecrets,omitempty"`
+	PullSecretsNamespace string            `yaml:"pullSecretsNamespace,omitempty"` // namespace from which the pull secrets are copied
+	Linkerd              LinkerdImages     `yaml:"linkerd,omitempty"`
+}
 
-	var versionResp map[string]string
+// HighAvailability holds the configuration for high-availability control plane tests
+type HighAvailability struct {
+	Skip bool `yaml:"skip,omitempty"`
+}
 
-	req, err := http.NewRequest("GET", versionEndpointURL, nil)
-	if err != nil {
-		return "", err
-	}
+// Resilience holds the configuration for control plane resilience tests
+type Resilience struct {
+	Skip           bool   `yaml:"skip,omitempty"`
+	OutageDuration string `yaml:"outageDuration,omitempty"` // how long each component is kept unavailable
+}
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
+// CheckConfig holds the assertions applied to each kind of `linkerd check` run
+type CheckConfig struct {
+	Pre   CheckAssertions `yaml:"pre,omitempty"`   // `linkerd check --pre`
+	Post  CheckAssertions `yaml:"post,omitempty"`  // `linkerd check`
+	Proxy CheckAssertions `yaml:"proxy,omitempty"` // `linkerd check --proxy`
+	Wait  string          `yaml:"wait,omitempty"`  // passed as `--wait` to every run
+}
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return "", err
-	}
+// AddOnTests holds the configuration for add-on tests
+type AddOnTests struct {
+	Skip bool `yaml:"skip,omitempty"`
+}
 
-	if err := json.Unmarshal(body, &versionResp); err != nil {
-		return "", err
-	}
+// Dashboard holds the configuration for dashboard tests
+type Dashboard struct {
+	Skip bool `yaml:"skip,omitempty"`
+}
 
-	return versionResp["edge"], nil
+// TrafficSplit holds the configuration for SMI TrafficSplit tests
+type TrafficSplit struct {
+	Skip      bool    `yaml:"skip,omitempty"`
+	Tolerance float64 `yaml:"tolerance,omitempty"` // maximum difference between the observed and expected share of each backend
+}
+
+// Egress holds the configuration for egress tests
+type Egress struct {
+	Skip bool `yaml:"skip,omitempty"`
+}
+
+// TestCase holds configuration of the various test cases
+type TestCase struct {
+	Lifecycle        `yaml:"lifecycle,omitempty"`
+	Inject           `yaml:"inject"`
+	Ingress          `yaml:"ingress"`
+	Dataplane        `yaml:"dataplane"`
+	HighAvailability `yaml:"ha"`
+	Resilience       `yaml:"resilience"`
+	AddOnTests       `yaml:"addOns"`
+	Dashboard        `yaml:"dashboard"`
+	TrafficSplit     `yaml:"trafficSplit"`
+	Egress           `yaml:"egress"`
+}
+
+// ConformanceTestOptions holds the values fed from the test config file
+type ConformanceTestOptions struct {
+	LinkerdVersion         string `yaml:"linkerdVersion,omitempty"`
+	LinkerdBinaryPath      string `yaml:"linkerdBinaryPath,omitempty"`
+	ClusterDomain          string `yaml:"clusterDomain,omitempty"`
+	K8sContext             string `yaml:"k8sContext,omitempty"`
+	ExternalIssuer         bool   `yaml:"externalIssuer,omitempty"`
+	KeepResourcesOnFailure bool   `yaml:"keepResourcesOnFailure,omitempty"` // keeps the resources created by failed specs for inspection
+	RunReport              string `yaml:"runReport,omitempty"`              // path of the JSON report holding the metadata and results of the run
+	Images                 `yaml:"images,omitempty"`
+	CheckConfig            `yaml:"check,omitempty"`
+	ControlPlane           `yaml:"controlPlane"`
+	TestCase               `yaml:"testCase"`
+	// TODO: Add fields for test specific configurations
+	// TODO: Add fields for Helm tests
 }
 
 func getDefaultLinkerdPath() (string, error) {
@@ -117,15 +195,8 @@ func initK8sHelper(context string, retryFor func(time.Duration, func() error) er
 
 func (options *ConformanceTestOptions) parse() error {
 	if options.LinkerdVersion == "" {
-		var version string
-		var err error
-
-		if version, err = getLatestStableVersion(); err != nil {
-			return fmt.Errorf("error fetching latest version: %s", err)
-		}
-
-		fmt.Printf("Unspecified linkerd2 version - using default value \"%s\"\n", version)
-		options.LinkerdVersion = version
+		fmt.Printf("Unspecified linkerd2 version - using default value \"%s\"\n", defaultLinkerdVersion)
+		options.LinkerdVersion = defaultLinkerdVersion
 	}
 
 	if options.ControlPlane.Namespace == "" {
@@ -156,12 +227,160 @@ func (options *ConformanceTestOptions) parse() error {
 		return errors.New("Cannot skip lifecycle tests when 'install.globalControlPlane.enable' is set to \"true\"")
 	}
 
+	if err := options.parseImages(); err != nil {
+		return err
+	}
+
+	if err := options.parseIngressConfig(); err != nil {
+		return err
+	}
+
+	if err := options.parseCheckConfig(); err != nil {
+		return err
+	}
+
+	if options.Resilience.OutageDuration == "" {
+		options.Resilience.OutageDuration = defaultOutageDuration
+	}
+
+	if _, err := time.ParseDuration(options.Resilience.OutageDuration); err != nil {
+		return fmt.Errorf("invalid 'testCase.resilience.outageDuration' %q: %s", options.Resilience.OutageDuration, err)
+	}
+
+	if options.TrafficSplit.Tolerance == 0 {
+		options.TrafficSplit.Tolerance = defaultSplitTolerance
+	}
+
+	if options.TrafficSplit.Tolerance < 0 || options.TrafficSplit.Tolerance >= 1 {
+		return fmt.Errorf("invalid 'testCase.trafficSplit.tolerance' %v: must be between 0 and 1", options.TrafficSplit.Tolerance)
+	}
+
 	if options.Lifecycle.UpgradeFromVersion != "" && options.SkipLifecycle() {
 		return errors.New("cannot skip lifecycle tests when 'install.upgradeFromVersion' is set - either enable install tests, or omit 'install.upgradeFromVersion'")
 	}
 	return nil
 }
 
+func (options *ConformanceTestOptions) parseCheckConfig() error {
+	if wait := options.CheckConfig.Wait; wait != "" {
+		if _, err := time.ParseDuration(wait); err != nil {
+			return fmt.Errorf("invalid 'check.wait' %q: %s", wait, err)
+		}
+	}
+
+	runs := map[string]CheckAssertions{
+		"pre":   options.CheckConfig.Pre,
+		"post":  options.CheckConfig.Post,
+		"proxy": options.CheckConfig.Proxy,
+	}
+
+	for run, a := range runs {
+		for _, ref := range append(append([]CheckRef{}, a.AllowedWarnings...), a.MustPass...) {
+			if ref.Category == "" || ref.Description == "" {
+				return fmt.Errorf("'check.%s' entries must specify both 'category' and 'description'", run)
+			}
+		}
+	}
+	return nil
+}
+
+func (custom *CustomIngressController) parse() error {
+	if custom.Name == "" || custom.Manifest == "" || custom.Namespace == "" || custom.Deployment == "" || custom.Resource == "" {
+		return errors.New("custom ingress controllers must specify 'name', 'manifest', 'namespace', 'deployment' and 'resource'")
+	}
+
+	if indexOf(builtinIngressControllers, custom.Name) > -1 {
+		return fmt.Errorf("custom ingress controller %q conflicts with a built-in controller", custom.Name)
+	}
+
+	if custom.Service == "" {
+		custom.Service = custom.Deployment
+	}
+
+	if custom.Port == 0 {
+		custom.Port = 80
+	}
+
+	if custom.Host == "" {
+		custom.Host = defaultIngressHost
+	}
+
+	if custom.Readiness.Timeout == "" {
+		custom.Readiness.Timeout = defaultIngressTimeout
+	}
+
+	if _, err := time.ParseDuration(custom.Readiness.Timeout); err != nil {
+		return fmt.Errorf("invalid readiness timeout for custom ingress controller %q: %s", custom.Name, err)
+	}
+
+	if custom.Readiness.StatusCode == 0 {
+		custom.Readiness.StatusCode = http.StatusOK
+	}
+
+	for _, version := range custom.IngressAPIVersions {
+		if indexOf(IngressAPIVersions, version) < 0 {
+			return fmt.Errorf("invalid Ingress API %q for custom ingress controller %q - must be one of %v", version, custom.Name, IngressAPIVersions)
+		}
+	}
+	return nil
+}
+
+func (options *ConformanceTestOptions) parseIngressConfig() error {
+	config := &options.TestCase.Ingress.IngressConfig
+
+	switch config.Reachability {
+	case "":
+		config.Reachability = ReachabilityLoadBalancer
+	case ReachabilityLoadBalancer, ReachabilityNodePort, ReachabilityPortForward, ReachabilityInCluster:
+	default:
+		return fmt.Errorf("unknown ingress reachability strategy %q - must be one of %q, %q, %q or %q", config.Reachability,
+			ReachabilityLoadBalancer, ReachabilityNodePort, ReachabilityPortForward, ReachabilityInCluster)
+	}
+
+	known := append([]string{}, builtinIngressControllers...)
+	for i := range config.Custom {
+		if err := config.Custom[i].parse(); err != nil {
+			return err
+		}
+		if indexOf(known, config.Custom[i].Name) > -1 {
+			return fmt.Errorf("custom ingress controller %q is declared more than once", config.Custom[i].Name)
+		}
+		known = append(known, config.Custom[i].Name)
+	}
+
+	for _, ctrl := range config.Controllers {
+		if indexOf(known, ctrl) == -1 {
+			return fmt.Errorf("unknown ingress controller %q - declare it under 'testCase.ingress.config.custom' to test a custom controller", ctrl)
+		}
+	}
+	return nil
+}
+
+func (options *ConformanceTestOptions) parseImages() error {
+	for registry, mirror := range options.Images.Registries {
+		if registry == "" || mirror == "" {
+			return errors.New("'images.registries' must map a registry to a non-empty mirror")
+		}
+	}
+
+	for image, override := range options.Images.Overrides {
+		if image == "" || override == "" {
+			return errors.New("'images.overrides' must map an image to a non-empty replacement")
+		}
+	}
+
+	for _, secret := range options.Images.PullSecrets {
+		if secret == "" {
+			return errors.New("'images.pullSecrets' must not contain empty secret names")
+		}
+	}
+
+	if options.Images.PullSecretsNamespace == "" {
+		options.Images.PullSecretsNamespace = defaultPullSecretsNamespace
+	}
+	return nil
+}
+
 func (options *ConformanceTestOptions) initNewTestHelperFromOptions() (*testutil.TestHelper, error) {
 	httpClient := http.Client{
 		Timeout: 10 * time.Second,
@@ -226,11 +445,6 @@ func (options *ConformanceTestOptions) SkipLifecycle() bool {
 	return !options.SingleControlPlane() && options.TestCase.Lifecycle.Skip
 }
 
-// CleanInject determines if resources created during inject test must be removed
-func (options *ConformanceTestOptions) CleanInject() bool {
-	return options.TestCase.Inject.Clean
-}
-
 // SkipInject determines if inject test must be skipped
 func (options *ConformanceTestOptions) SkipInject() bool {
 	return options.TestCase.Inject.Skip
@@ -241,9 +455,67 @@ func (options *ConformanceTestOptions) GetAddons() map[string]interface{} {
 	return options.ControlPlane.ControlPlaneConfig.AddOns
 }
 
+// mergeValues returns the values of base (if any) overridden by overrides
+func mergeValues(base interface{}, overrides map[interface{}]interface{}) map[interface{}]interface{} {
+	merged := map[interface{}]interface{}{}
+	if values, ok := base.(map[interface{}]interface{}); ok {
+		for k, v := range values {
+			merged[k] = v
+		}
+	}
+
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// getAddOnValues returns the add-on config, along with the values
+// replacing the bundled Prometheus when an external one is used
+func (options *ConformanceTestOptions) getAddOnValues() map[string]interface{} {
+	values := map[string]interface{}{}
+	for k, v := range options.GetAddons() {
+		values[k] = v
+	}
+
+	if options.ExternalPrometheus() {
+		values[Prometheus] = mergeValues(values[Prometheus], map[interface{}]interface{}{"enabled": false})
+		values["global"] = mergeValues(values["global"], map[interface{}]interface{}{"prometheusUrl": options.GetExternalPrometheusURL()})
+	}
+	return values
+}
+
+// HasAddOnConfig determines if `linkerd install` must be passed an add-on config
+func (options *ConformanceTestOptions) HasAddOnConfig() bool {
+	return len(options.getAddOnValues()) > 0
+}
+
+// IsAddOnEnabled determines if the given add-on is enabled by the add-on config
+func (options *ConformanceTestOptions) IsAddOnEnabled(name string) bool {
+	enabled := defaultAddOns[name]
+	if values, ok := options.getAddOnValues()[name].(map[interface{}]interface{}); ok {
+		if e, ok := values["enabled"].(bool); ok {
+			enabled = e
+		}
+	}
+	return enabled
+}
+
+// IsComponentEnabled returns false for the control plane
+// deployments which were disabled through the add-on config
+func (options *ConformanceTestOptions) IsComponentEnabled(deploy string) bool {
+	switch deploy {
+	case grafanaDeploy:
+		return options.IsAddOnEnabled(Grafana)
+	case PrometheusDeploy:
+		return options.IsAddOnEnabled(Prometheus)
+	}
+	return true
+}
+
 // GetAddOnsYAML marshals the add-on config to a YAML and returns the byte slice and error
 func (options *ConformanceTestOptions) GetAddOnsYAML() (out []byte, err error) {
-	return yaml.Marshal(options.GetAddons())
+	return yaml.Marshal(options.getAddOnValues())
 }
 
 // GetInstallFlags returns the flags set by the user for running `linkerd install`
@@ -251,6 +523,11 @@ func (options *ConformanceTestOptions) GetInstallFlags() []string {
 	return options.ControlPlane.ControlPlaneConfig.Flags
 }
 
+// CNIEnabled determines if the control plane must be installed along with the linkerd-cni plugin
+func (options *ConformanceTestOptions) CNIEnabled() bool {
+	return options.ControlPlane.ControlPlaneConfig.CNI
+}
+
 // SkipIngress determines if ingress tests must be skipped
 func (options *ConformanceTestOptions) SkipIngress() bool {
 	return options.TestCase.Ingress.Skip
@@ -260,3 +537,193 @@ func (options *ConformanceTestOptions) SkipIngress() bool {
 func (options *ConformanceTestOptions) ShouldTestIngressOfType(t string) bool {
 	return indexOf(options.TestCase.Ingress.IngressConfig.Controllers, t) > -1
 }
+
+// GetIngressReachability returns the strategy used for reaching ingress controllers
+func (options *ConformanceTestOptions) GetIngressReachability() string {
+	return options.TestCase.Ingress.IngressConfig.Reachability
+}
+
+// GetIngressNodeAddress returns the node address to be used with the nodePort reachability strategy
+func (options *ConformanceTestOptions) GetIngressNodeAddress() string {
+	return options.TestCase.Ingress.IngressConfig.NodeAddress
+}
+
+// GetCustomIngressControllers returns the user-defined ingress controllers
+func (options *ConformanceTestOptions) GetCustomIngressControllers() []CustomIngressController {
+	return options.TestCase.Ingress.IngressConfig.Custom
+}
+
+// SkipDataplane determines if data plane health tests must be skipped
+func (options *ConformanceTestOptions) SkipDataplane() bool {
+	return options.TestCase.Dataplane.Skip
+}
+
+// HasImageConfig determines if any image overrides or pull secrets must be applied to the test workloads
+func (options *ConformanceTestOptions) HasImageConfig() bool {
+	return len(options.Images.Registries) > 0 || len(options.Images.Overrides) > 0 || len(options.Images.PullSecrets) > 0
+}
+
+// GetImagePullSecrets returns the image pull secrets added to every pod spec
+func (options *ConformanceTestOptions) GetImagePullSecrets() []string {
+	return options.Images.PullSecrets
+}
+
+// GetImagePullSecretsNamespace returns the namespace from which the image pull secrets are copied
+func (options *ConformanceTestOptions) GetImagePullSecretsNamespace() string {
+	return options.Images.PullSecretsNamespace
+}
+
+// ResolveImage returns the image that must be used in place of the
+// given one, giving precedence to overrides over registry mirrors
+func (options *ConformanceTestOptions) ResolveImage(image string) string {
+	if override, ok := options.Images.Overrides[image]; ok {
+		return override
+	}
+
+	registry, repository := splitImage(image)
+	if mirror, ok := options.Images.Registries[registry]; ok {
+		return strings.TrimSuffix(mirror, "/") + "/" + repository
+	}
+	return image
+}
+
+func (options *ConformanceTestOptions) getLinkerdImageFlags() []string {
+	linkerd := options.Images.Linkerd
+	flags := []string{}
+
+	if linkerd.Registry != "" {
+		flags = append(flags, "--registry", linkerd.Registry)
+	}
+
+	if linkerd.ProxyImage != "" {
+		flags = append(flags, "--proxy-image", linkerd.ProxyImage)
+	}
+
+	if linkerd.InitImage != "" {
+		flags = append(flags, "--init-image", linkerd.InitImage)
+	}
+	return flags
+}
+
+// GetInstallImageFlags returns the image flags for `linkerd install` and `linkerd upgrade`
+func (options *ConformanceTestOptions) GetInstallImageFlags() []string {
+	return options.getLinkerdImageFlags()
+}
+
+// GetInjectImageFlags returns the image flags for `linkerd inject`
+func (options *ConformanceTestOptions) GetInjectImageFlags() []string {
+	return options.getLinkerdImageFlags()
+}
+
+// GetDebugImage returns the image set on the workloads of the debug sidecar tests through the
+// `config.linkerd.io/debug-image` annotation, if any. As `linkerd inject --registry` overrides
+// this annotation, no image is returned for manually injected workloads when a registry is set
+func (options *ConformanceTestOptions) GetDebugImage(manual bool) string {
+	if manual && options.Images.Linkerd.Registry != "" {
+		return ""
+	}
+	return options.Images.Linkerd.DebugImage
+}
+
+// ShouldKeepResourcesOnFailure determines if the resources created by failed specs must be kept for inspection
+func (options *ConformanceTestOptions) ShouldKeepResourcesOnFailure() bool {
+	return options.KeepResourcesOnFailure
+}
+
+// GetRunReportPath returns the path of the run report, which defaults to the path of
+// the JUnit report (`--ginkgo.reportFile`) with a ".json" extension. It is suffixed
+// with the Ginkgo node number when running in parallel. If neither is set, an
+// empty string is returned and no run report is written
+func (options *ConformanceTestOptions) GetRunReportPath() string {
+	path := options.RunReport
+	if path == "" {
+		junit := ginkgoconfig.DefaultReporterConfig.ReportFile
+		if junit == "" {
+			return ""
+		}
+		path = strings.TrimSuffix(junit, filepath.Ext(junit)) + ".json"
+	}
+
+	if Parallel() {
+		ext := filepath.Ext(path)
+		path = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(path, ext), ginkgoconfig.GinkgoConfig.ParallelNode, ext)
+	}
+	return path
+}
+
+// SkipHA determines if high-availability control plane tests must be skipped
+func (options *ConformanceTestOptions) SkipHA() bool {
+	return options.TestCase.HighAvailability.Skip
+}
+
+// SkipResilience determines if control plane resilience tests must be skipped
+func (options *ConformanceTestOptions) SkipResilience() bool {
+	return options.TestCase.Resilience.Skip
+}
+
+// GetOutageDuration returns how long each control plane component is kept unavailable by the resilience tests
+func (options *ConformanceTestOptions) GetOutageDuration() time.Duration {
+	d, _ := time.ParseDuration(options.TestCase.Resilience.OutageDuration)
+	return d
+}
+
+// GetPreCheckAssertions returns the assertions applied to the output of `linkerd check --pre`
+func (options *ConformanceTestOptions) GetPreCheckAssertions() CheckAssertions {
+	return options.CheckConfig.Pre
+}
+
+// GetPostCheckAssertions returns the assertions applied to the output of `linkerd check`
+func (options *ConformanceTestOptions) GetPostCheckAssertions() CheckAssertions {
+	return options.CheckConfig.Post
+}
+
+// GetProxyCheckAssertions returns the assertions applied to the output of `linkerd check --proxy`
+func (options *ConformanceTestOptions) GetProxyCheckAssertions() CheckAssertions {
+	return options.CheckConfig.Proxy
+}
+
+// GetCheckWait returns the maximum time `linkerd check` waits for the checks to pass
+func (options *ConformanceTestOptions) GetCheckWait() string {
+	return options.CheckConfig.Wait
+}
+
+// SkipAddOns determines if add-on tests must be skipped
+func (options *ConformanceTestOptions) SkipAddOns() bool {
+	return options.TestCase.AddOnTests.Skip
+}
+
+// ExternalPrometheus determines if the control plane must use an external Prometheus instead of the bundled one
+func (options *ConformanceTestOptions) ExternalPrometheus() bool {
+	return options.ControlPlane.ControlPlaneConfig.ExternalPrometheus
+}
+
+// GetExternalPrometheusNamespace returns the namespace in which the external Prometheus is installed
+func (options *ConformanceTestOptions) GetExternalPrometheusNamespace() string {
+	return fmt.Sprintf("%s-%s", options.ControlPlane.Namespace, externalPrometheusNsSuffix)
+}
+
+// GetExternalPrometheusURL returns the URL of the external Prometheus, from within the cluster
+func (options *ConformanceTestOptions) GetExternalPrometheusURL() string {
+	return fmt.Sprintf("http://%s.%s.svc.%s:%d", ExternalPrometheusDeploy, options.GetExternalPrometheusNamespace(), options.ClusterDomain, PrometheusPort)
+}
+
+// SkipDashboard determines if dashboard tests must be skipped
+func (options *ConformanceTestOptions) SkipDashboard() bool {
+	return options.TestCase.Dashboard.Skip
+}
+
+// SkipTrafficSplit determines if TrafficSplit tests must be skipped
+func (options *ConformanceTestOptions) SkipTrafficSplit() bool {
+	return options.TestCase.TrafficSplit.Skip
+}
+
+// GetSplitTolerance returns the maximum difference between the observed
+// and expected share of traffic received by each backend of a TrafficSplit
+func (options *ConformanceTestOptions) GetSplitTolerance() float64 {
+	return options.TestCase.TrafficSplit.Tolerance
+}
+
+// SkipEgress determines if egress tests must be skipped
+func (options *ConformanceTestOptions) SkipEgress() bool {
+	return options.TestCase.Egress.Skip
+}
@@ -5,7 +5,8 @@ const (
 	defaultClusterDomain = "cluster.local"
 	defaultPath          = "/.linkerd2/bin/linkerd"
 
-	versionEndpointURL = "https://versioncheck.linkerd.io/version.json"
+	// defaultLinkerdVersion is the release the golden files are validated against
+	defaultLinkerdVersion = "stable-2.8.1"
 
 	//TODO: move these to ConformanceTestOptions while writing Helm tests
 	helmPath        = "target/helm"
@@ -23,20 +24,151 @@ const (
 	linkerdInstallScript = "install.sh"
 	installScriptURL     = "https://run.linkerd.io/install"
 
+	goldenIndexFile = "index.yaml"
+
+	defaultPullSecretsNamespace = "default"
+
+	// HAReplicas is the number of replicas of the control plane
+	// components replicated in high-availability mode
+	HAReplicas = 3
+
+	defaultOutageDuration = "30s"
+
+	defaultSplitTolerance = 0.1
+
+	// names of the add-ons, as used in `controlPlane.config.addOns`
+
+	// Grafana holds the string literal "grafana"
+	Grafana = "grafana"
+
+	// Tracing holds the string literal "tracing"
+	Tracing = "tracing"
+
+	// Prometheus holds the string literal "prometheus"
+	Prometheus = "prometheus"
+
+	// PrometheusDeploy is the Prometheus bundled with the control plane
+	PrometheusDeploy = "linkerd-prometheus"
+
+	// ExternalPrometheusDeploy is the Prometheus used instead of the bundled
+	// one when `controlPlane.config.externalPrometheus` is set
+	ExternalPrometheusDeploy = "prometheus"
+
+	// PrometheusPort is the port on which Prometheus serves its API
+	PrometheusPort = 9090
+
+	externalPrometheusNsSuffix = "external-prometheus"
+	externalPrometheusManifest = "testdata/prometheus/external.yaml"
+
+	grafanaDeploy = "linkerd-grafana"
+
+	// CNINamespace is the namespace in which the linkerd-cni plugin is installed
+	CNINamespace = "linkerd-cni"
+
+	// CNIDaemonSet runs the linkerd-cni plugin on each node
+	CNIDaemonSet = "linkerd-cni"
+
+	// CollectorDeploy is the OpenCensus collector of the tracing add-on
+	CollectorDeploy = "linkerd-collector"
+
+	// JaegerDeploy is the Jaeger instance of the tracing add-on
+	JaegerDeploy = "linkerd-jaeger"
+
+	// WebDeploy serves the Linkerd dashboard
+	WebDeploy = "linkerd-web"
+
+	// WebPort is the port on which the dashboard is served
+	WebPort = 8084
+
 	// string literals for identifying the ingress controllers
 
 	// Nginx holds the string literal "nginx"
 	Nginx = "nginx"
 
-	// Traefik    = "traefik"
+	// Traefik holds the string literal "traefik"
+	Traefik = "traefik"
+
+	// Ambassador holds the string literal "ambassador"
+	Ambassador = "ambassador"
+
+	// Gloo holds the string literal "gloo"
+	Gloo = "gloo"
+
+	// Contour holds the string literal "contour"
+	Contour = "contour"
+
 	// GCE        = "gce"
-	// Ambassador = "ambassador"
-	// Gloo       = "gloo"
-	// Contour    = "contour"
+
+	defaultIngressHost    = "example.com"
+	defaultIngressTimeout = "5m"
+
+	// strategies for reaching ingress controllers from the test runner
+
+	// ReachabilityLoadBalancer uses the IP or hostname of the controller's LoadBalancer service
+	ReachabilityLoadBalancer = "loadBalancer"
+
+	// ReachabilityNodePort uses the node port of the controller's service
+	ReachabilityNodePort = "nodePort"
+
+	// ReachabilityPortForward port-forwards to the controller's pod
+	ReachabilityPortForward = "portForward"
+
+	// ReachabilityInCluster sends requests to the controller's service from a Job running in the cluster
+	ReachabilityInCluster = "inCluster"
+
+	// CurlImage is the image used for sending requests from within the cluster
+	CurlImage = "curlimages/curl:7.71.1"
+
+	// images used by the test workloads under `testdata`
+	bbImage         = "buoyantio/bb:v0.0.5"
+	emojiSvcImage   = "buoyantio/emojivoto-emoji-svc:v10"
+	votingSvcImage  = "buoyantio/emojivoto-voting-svc:v10"
+	webImage        = "buoyantio/emojivoto-web:v10"
+	prometheusImage = "prom/prometheus:v2.15.2"
 
 	// NginxNs is the namespace in which the nginx controller is installed
 	NginxNs = "ingress-nginx"
 
 	// NginxController is the name of the nginx controller
 	NginxController = "ingress-nginx-controller"
+
+	// TraefikNs is the namespace in which the traefik controller is installed
+	TraefikNs = "ingress-traefik"
+
+	// TraefikController is the name of the traefik controller
+	TraefikController = "traefik-ingress-controller"
+
+	// AmbassadorNs is the namespace in which the ambassador controller is installed
+	AmbassadorNs = "ingress-ambassador"
+
+	// AmbassadorController is the name of the ambassador controller
+	AmbassadorController = "ambassador"
+
+	// GlooNs is the namespace in which the gloo controller is installed
+	GlooNs = "ingress-gloo"
+
+	// GlooController is the name of the gloo ingress proxy
+	GlooController = "ingress-proxy"
+
+	// ContourNs is the namespace in which the contour controller is installed
+	ContourNs = "ingress-contour"
+
+	// ContourController is the name of the envoy proxy managed by contour
+	ContourController = "envoy"
 )
+
+var builtinIngressControllers = []string{Nginx, Traefik, Ambassador, Gloo, Contour}
+
+// IngressAPIVersions holds the group versions of the Ingress API, newest first
+var IngressAPIVersions = []string{
+	"networking.k8s.io/v1",
+	"networking.k8s.io/v1beta1",
+	"extensions/v1beta1",
+}
+
+// defaultAddOns holds whether each add-on is enabled when not specified in `controlPlane.config.addOns`
+var defaultAddOns = map[string]bool{
+	Prometheus: true,
+	Grafana:    true,
+	Tracing:    false,
+}
@@ -0,0 +1,285 @@
+package utils
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/linkerd/linkerd2/pkg/version"
+	"github.com/linkerd/linkerd2/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "regenerate golden files from the current CLI output instead of validating against them")
+
+// goldenRange maps a range of linkerd2 versions to a directory of golden files
+type goldenRange struct {
+	Dir        string `yaml:"dir"`
+	MinVersion string `yaml:"minVersion"`
+	MaxVersion string `yaml:"maxVersion"`
+}
+
+// goldenIndex is used for unmarshalling `goldens/index.yaml`
+type goldenIndex struct {
+	Ranges []goldenRange `yaml:"ranges"`
+}
+
+// parseVersion splits a linkerd2 version such as "stable-2.8.1"
+// or "edge-20.6.4" into its release channel and numeric components
+func parseVersion(version string) (string, []int, error) {
+	parts := strings.SplitN(version, "-", 2)
+	if len(parts) != 2 {
+		return "", nil, fmt.Errorf("unrecognized version format: %s", version)
+	}
+
+	var nums []int
+	for _, n := range strings.Split(parts[1], ".") {
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return "", nil, fmt.Errorf("unrecognized version format: %s", version)
+		}
+		nums = append(nums, i)
+	}
+	return parts[0], nums, nil
+}
+
+func compareVersionParts(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+// contains checks if the given version falls within the range r.
+// Ranges must be closed, and both of their bounds must belong to the same channel
+func (r goldenRange) contains(channel string, version []int) (bool, error) {
+	if r.MinVersion == "" || r.MaxVersion == "" {
+		return false, fmt.Errorf("range of %s must declare both minVersion and maxVersion", r.Dir)
+	}
+
+	minChannel, min, err := parseVersion(r.MinVersion)
+	if err != nil {
+		return false, err
+	}
+
+	maxChannel, max, err := parseVersion(r.MaxVersion)
+	if err != nil {
+		return false, err
+	}
+
+	if minChannel != maxChannel {
+		return false, fmt.Errorf("range of %s mixes %s and %s versions", r.Dir, minChannel, maxChannel)
+	}
+
+	return channel == minChannel &&
+		compareVersionParts(version, min) >= 0 &&
+		compareVersionParts(version, max) < 0, nil
+}
+
+// ResolveGoldenFile returns the path (relative to `testdata/`) of the golden
+// file `name` for the given linkerd2 version, as declared in
+// `testdata/<dir>/goldens/index.yaml`. An error is returned if the
+// version does not fall within any of the declared ranges
+func ResolveGoldenFile(dir, name, version string) (string, error) {
+	goldensDir := filepath.Join(dir, "goldens")
+	indexFile := filepath.Join("testdata", goldensDir, goldenIndexFile)
+
+	data, err := ioutil.ReadFile(indexFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read golden index: %s", err)
+	}
+
+	var index goldenIndex
+	if err := yaml.UnmarshalStrict(data, &index); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %s", indexFile, err)
+	}
+
+	channel, v, err := parseVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	for _, r := range index.Ranges {
+		ok, err := r.contains(channel, v)
+		if err != nil {
+			return "", fmt.Errorf("invalid range in %s: %s", indexFile, err)
+		}
+		if ok {
+			return filepath.Join(goldensDir, r.Dir, name), nil
+		}
+	}
+
+	return "", fmt.Errorf("no golden files declared for version %s in %s - add a range for it and run the tests with -update-golden", version, indexFile)
+}
+
+// ValidateInjectGolden validates the output of `linkerd inject` against the
+// golden file `name` resolved for the version under test. If the tests are
+// run with `-update-golden`, the golden file is overwritten with the output.
+func ValidateInjectGolden(h *testutil.TestHelper, actual, dir, name string) error {
+	golden, err := ResolveGoldenFile(dir, name, h.GetVersion())
+	if err != nil {
+		return err
+	}
+
+	if *updateGolden {
+		path := filepath.Join("testdata", golden)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		fmt.Printf("Updating golden file %s\n", path)
+		return ioutil.WriteFile(path, []byte(actual), 0644)
+	}
+
+	expected, err := testutil.ReadFile(filepath.Join("testdata", golden))
+	if err != nil {
+		return err
+	}
+
+	expected, err = pinInjectVersions(expected, h.GetVersion())
+	if err != nil {
+		return fmt.Errorf("failed to parse golden file %s: %s", golden, err)
+	}
+
+	actual, err = pinInjectVersions(actual, h.GetVersion())
+	if err != nil {
+		return fmt.Errorf("failed to parse output: %s", err)
+	}
+
+	if expected != actual {
+		return fmt.Errorf("output does not match golden file %s (run with -update-golden to regenerate it):\n%s", golden, diffLines(expected, actual))
+	}
+	return nil
+}
+
+// pinInjectVersions pins the values of an injected manifest which vary from
+// build to build, as done by `testutil.ValidateInject`: the version in the
+// `linkerd.io/created-by` and `linkerd.io/proxy-version` annotations, and the
+// image tag of the proxy-init container. The manifest is re-marshalled, so that
+// the golden file and the output can be compared and diffed in the same format
+func pinInjectVersions(manifest, linkerdVersion string) (string, error) {
+	var buf bytes.Buffer
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc yaml.MapSlice
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if len(doc) == 0 {
+			continue
+		}
+
+		if template, ok := getMapSliceValue(doc, "spec"); ok {
+			if template, ok := template.(yaml.MapSlice); ok {
+				if template, ok := getMapSliceValue(template, "template"); ok {
+					if template, ok := template.(yaml.MapSlice); ok {
+						pinPodTemplateVersions(template, linkerdVersion)
+					}
+				}
+			}
+		}
+
+		if err := writeYAMLDocument(&buf, doc); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+func pinPodTemplateVersions(template yaml.MapSlice, linkerdVersion string) {
+	if metadata, ok := getMapSliceValue(template, "metadata"); ok {
+		if metadata, ok := metadata.(yaml.MapSlice); ok {
+			if annotations, ok := getMapSliceValue(metadata, "annotations"); ok {
+				if annotations, ok := annotations.(yaml.MapSlice); ok {
+					pinMapSliceValue(annotations, "linkerd.io/created-by", "linkerd/cli "+linkerdVersion)
+					pinMapSliceValue(annotations, "linkerd.io/proxy-version", linkerdVersion)
+				}
+			}
+		}
+	}
+
+	if spec, ok := getMapSliceValue(template, "spec"); ok {
+		if spec, ok := spec.(yaml.MapSlice); ok {
+			if containers, ok := getMapSliceValue(spec, "initContainers"); ok {
+				if containers, ok := containers.([]interface{}); ok && len(containers) > 0 {
+					if container, ok := containers[0].(yaml.MapSlice); ok {
+						pinMapSliceValue(container, "image", "init-image:"+version.ProxyInitVersion)
+					}
+				}
+			}
+		}
+	}
+}
+
+// pinMapSliceValue replaces the value of key, if present
+func pinMapSliceValue(m yaml.MapSlice, key string, value interface{}) {
+	if _, ok := getMapSliceValue(m, key); ok {
+		setMapSliceValue(m, key, value)
+	}
+}
+
+// diffLines returns a line based diff between two strings, where lines
+// only present in `expected` are prefixed with "-", lines only present in
+// `actual` are prefixed with "+" and unchanged lines are omitted
+func diffLines(expected, actual string) string {
+	a := strings.Split(strings.TrimSuffix(expected, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(actual, "\n"), "\n")
+
+	// lcs[i][j] holds the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	inHunk := false
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			i++
+			j++
+			inHunk = false
+			continue
+		}
+
+		if !inHunk {
+			fmt.Fprintf(&out, "@@ expected line %d, actual line %d\n", i+1, j+1)
+			inHunk = true
+		}
+
+		if i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]) {
+			fmt.Fprintf(&out, "- %s\n", a[i])
+			i++
+		} else {
+			fmt.Fprintf(&out, "+ %s\n", b[j])
+			j++
+		}
+	}
+	return out.String()
+}
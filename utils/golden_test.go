@@ -0,0 +1,313 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/linkerd/linkerd2/pkg/version"
+)
+
+func TestParseVersion(t *testing.T) {
+	testCases := []struct {
+		version string
+		channel string
+		parts   []int
+		err     bool
+	}{
+		{version: "stable-2.8.1", channel: "stable", parts: []int{2, 8, 1}},
+		{version: "stable-2.10.0", channel: "stable", parts: []int{2, 10, 0}},
+		{version: "edge-20.6.4", channel: "edge", parts: []int{20, 6, 4}},
+		{version: "2.8.1", err: true},
+		{version: "stable-2.8.x", err: true},
+		{version: "stable-", err: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.version, func(t *testing.T) {
+			channel, parts, err := parseVersion(tc.version)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("Expected an error for version %s", tc.version)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if channel != tc.channel {
+				t.Fatalf("Expected channel %s, got %s", tc.channel, channel)
+			}
+			if !reflect.DeepEqual(parts, tc.parts) {
+				t.Fatalf("Expected parts %v, got %v", tc.parts, parts)
+			}
+		})
+	}
+}
+
+func TestCompareVersionParts(t *testing.T) {
+	testCases := []struct {
+		a, b []int
+		sign int
+	}{
+		{a: []int{2, 8, 1}, b: []int{2, 8, 1}, sign: 0},
+		{a: []int{2, 8, 0}, b: []int{2, 8, 1}, sign: -1},
+		{a: []int{2, 9, 0}, b: []int{2, 8, 1}, sign: 1},
+		{a: []int{2, 8}, b: []int{2, 10}, sign: -1},
+		{a: []int{2, 8}, b: []int{2, 8, 0}, sign: -1},
+		{a: []int{2, 8, 0, 1}, b: []int{2, 8, 0}, sign: 1},
+	}
+
+	for _, tc := range testCases {
+		actual := compareVersionParts(tc.a, tc.b)
+		if (actual < 0 && tc.sign >= 0) || (actual > 0 && tc.sign <= 0) || (actual == 0 && tc.sign != 0) {
+			t.Errorf("Expected compareVersionParts(%v, %v) to have sign %d, got %d", tc.a, tc.b, tc.sign, actual)
+		}
+	}
+}
+
+// writeGoldenIndex writes a golden index for the feature `dir` under a temporary
+// directory, and changes the working directory to it until the test ends
+func writeGoldenIndex(t *testing.T, dir, index string) {
+	tmp, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	goldensDir := filepath.Join(tmp, "testdata", dir, "goldens")
+	if err := os.MkdirAll(goldensDir, 0755); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(goldensDir, goldenIndexFile), []byte(index), 0644); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(tmp)
+	})
+}
+
+func TestResolveGoldenFile(t *testing.T) {
+	writeGoldenIndex(t, "inject", `
+ranges:
+  - dir: stable-2.8
+    minVersion: stable-2.8.0
+    maxVersion: stable-2.9.0
+  - dir: stable-2.9
+    minVersion: stable-2.9.0
+    maxVersion: stable-2.10.0
+  - dir: stable-2.8
+    minVersion: edge-20.6.1
+    maxVersion: edge-20.7.1
+`)
+
+	testCases := []struct {
+		version  string
+		expected string
+		err      bool
+	}{
+		{version: "stable-2.8.0", expected: "inject/goldens/stable-2.8/inject_default.golden"},
+		{version: "stable-2.8.1", expected: "inject/goldens/stable-2.8/inject_default.golden"},
+		{version: "stable-2.9.0", expected: "inject/goldens/stable-2.9/inject_default.golden"},
+		{version: "stable-2.9.4", expected: "inject/goldens/stable-2.9/inject_default.golden"},
+		{version: "edge-20.6.4", expected: "inject/goldens/stable-2.8/inject_default.golden"},
+		{version: "stable-2.7.1", err: true},
+		{version: "stable-2.10.0", err: true},
+		{version: "edge-20.7.1", err: true},
+		{version: "dev-abcdef", err: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.version, func(t *testing.T) {
+			actual, err := ResolveGoldenFile("inject", "inject_default.golden", tc.version)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("Expected an error for version %s, got %s", tc.version, actual)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if actual != tc.expected {
+				t.Fatalf("Expected golden file %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResolveGoldenFileInvalidRanges(t *testing.T) {
+	testCases := []struct {
+		name  string
+		index string
+	}{
+		{
+			name: "open range",
+			index: `
+ranges:
+  - dir: stable-2.8
+    minVersion: stable-2.8.0
+`,
+		},
+		{
+			name: "mixed channels",
+			index: `
+ranges:
+  - dir: stable-2.8
+    minVersion: stable-2.8.0
+    maxVersion: edge-20.7.1
+`,
+		},
+		{
+			name: "unknown field",
+			index: `
+ranges:
+  - dir: stable-2.8
+    minVersion: stable-2.8.0
+    maxVersion: stable-2.9.0
+    version: stable-2.8.1
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			writeGoldenIndex(t, "inject", tc.index)
+
+			if actual, err := ResolveGoldenFile("inject", "inject_default.golden", "stable-2.8.1"); err == nil {
+				t.Fatalf("Expected an error, got %s", actual)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+		actual   string
+		diff     string
+	}{
+		{
+			name:     "equal",
+			expected: "a\nb\nc\n",
+			actual:   "a\nb\nc\n",
+			diff:     "",
+		},
+		{
+			name:     "changed line",
+			expected: "a\nb\nc\n",
+			actual:   "a\nx\nc\n",
+			diff:     "@@ expected line 2, actual line 2\n- b\n+ x\n",
+		},
+		{
+			name:     "added line",
+			expected: "a\nc\n",
+			actual:   "a\nb\nc\n",
+			diff:     "@@ expected line 2, actual line 2\n+ b\n",
+		},
+		{
+			name:     "removed lines",
+			expected: "a\nb\nc\nd\n",
+			actual:   "a\nd\n",
+			diff:     "@@ expected line 2, actual line 2\n- b\n- c\n",
+		},
+		{
+			name:     "separate hunks",
+			expected: "a\nb\nc\nd\n",
+			actual:   "x\nb\nc\ny\n",
+			diff:     "@@ expected line 1, actual line 1\n- a\n+ x\n@@ expected line 4, actual line 4\n- d\n+ y\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := diffLines(tc.expected, tc.actual); diff != tc.diff {
+				t.Fatalf("Expected diff:\n%s\ngot:\n%s", tc.diff, diff)
+			}
+		})
+	}
+}
+
+func TestPinInjectVersions(t *testing.T) {
+	injected := func(version, initImage string) string {
+		return fmt.Sprintf(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: terminus
+spec:
+  template:
+    metadata:
+      annotations:
+        linkerd.io/created-by: linkerd/cli %s
+        linkerd.io/proxy-version: %s
+    spec:
+      containers:
+      - name: bb-terminus
+        image: buoyantio/bb:v0.0.5
+      initContainers:
+      - name: linkerd-init
+        image: %s
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: terminus
+`, version, version, initImage)
+	}
+
+	expected, err := pinInjectVersions(injected("stable-2.8.0", "init-image:v1.2.0"), "stable-2.8.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	actual, err := pinInjectVersions(injected("stable-2.8.1", "init-image:v1.3.3"), "stable-2.8.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if expected != actual {
+		t.Fatalf("Expected pinned manifests to be equal, got diff:\n%s", diffLines(expected, actual))
+	}
+
+	for _, s := range []string{
+		"linkerd.io/created-by: linkerd/cli stable-2.8.1",
+		"linkerd.io/proxy-version: stable-2.8.1",
+		"image: init-image:" + version.ProxyInitVersion,
+		"image: buoyantio/bb:v0.0.5",
+		"kind: Service",
+	} {
+		if !strings.Contains(actual, s) {
+			t.Fatalf("Expected pinned manifest to contain %q, got:\n%s", s, actual)
+		}
+	}
+
+	different, err := pinInjectVersions(strings.Replace(injected("stable-2.8.1", "init-image:v1.3.3"), "bb:v0.0.5", "bb:v0.0.6", 1), "stable-2.8.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	diff := diffLines(expected, different)
+	if !strings.Contains(diff, "-         image: buoyantio/bb:v0.0.5\n+         image: buoyantio/bb:v0.0.6\n") || strings.Count(diff, "\n") != 3 {
+		t.Fatalf("Unexpected diff:\n%s", diff)
+	}
+}
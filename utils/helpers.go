@@ -2,10 +2,14 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/linkerd/linkerd2/testutil"
 	"gopkg.in/yaml.v2"
@@ -14,6 +18,8 @@ import (
 var (
 	testHelper *testutil.TestHelper
 	testConfig *ConformanceTestOptions
+
+	initOnce sync.Once
 )
 
 // InitNewHelperAndConfig returns an instance of testutil.TestHelper and ConformanceTestOptions
@@ -143,15 +149,98 @@ func Err(err error) string {
 	return ""
 }
 
-// GetHelperAndConfig returns a reference to the initialized `testHelper` and `testConfig`
-func GetHelperAndConfig() (*testutil.TestHelper, *ConformanceTestOptions) {
-	return testHelper, testConfig
+// IsStreamTimeout checks if an error was returned by `Stream.ReadUntil` as the
+// requested number of lines could not be read before the timeout
+func IsStreamTimeout(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Timed out trying to read")
+}
+
+// parseYAMLDocuments decodes every non-empty document in a
+// multi-document YAML string into its normalized form
+func parseYAMLDocuments(in string) ([]interface{}, error) {
+	var docs []interface{}
+	decoder := yaml.NewDecoder(strings.NewReader(in))
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if doc = normalizeYAML(doc); doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
 }
 
-func init() {
-	err := initNewHelperAndConfig()
+// isSerializationArtifact checks if a field is one of the empty fields added when
+// `linkerd inject` and `linkerd uninject` serialize Kubernetes objects, such as
+// `creationTimestamp: null` or `resources: {}`
+func isSerializationArtifact(key string, val interface{}) bool {
+	switch key {
+	case "creationTimestamp":
+		return val == nil
+	case "resources", "status", "strategy":
+		m, ok := val.(map[interface{}]interface{})
+		return ok && len(m) == 0
+	}
+	return false
+}
+
+// normalizeYAML converts decoded YAML maps to map[string]interface{},
+// and drops the serialization artifacts of inject and uninject so that
+// they do not affect comparisons. Any other empty or null value is kept
+func normalizeYAML(in interface{}) interface{} {
+	switch v := in.(type) {
+	case map[interface{}]interface{}:
+		out := map[string]interface{}{}
+		for key, val := range v {
+			k := fmt.Sprintf("%v", key)
+			if !isSerializationArtifact(k, val) {
+				out[k] = normalizeYAML(val)
+			}
+		}
+		return out
+	case []interface{}:
+		out := []interface{}{}
+		for _, val := range v {
+			out = append(out, normalizeYAML(val))
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// YAMLEqual checks if two (multi-document) YAML strings are semantically equal,
+// ignoring key ordering, whitespace and the serialization artifacts of inject
+func YAMLEqual(expected, actual string) (bool, error) {
+	expectedDocs, err := parseYAMLDocuments(expected)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse expected YAML: %s", err)
+	}
+
+	actualDocs, err := parseYAMLDocuments(actual)
 	if err != nil {
-		fmt.Printf("failed to initialize test helper or config: %s", err.Error())
-		os.Exit(1)
+		return false, fmt.Errorf("failed to parse actual YAML: %s", err)
 	}
+
+	return reflect.DeepEqual(expectedDocs, actualDocs), nil
+}
+
+// GetHelperAndConfig returns a reference to the initialized `testHelper` and `testConfig`.
+// They are initialized on the first call, so that the unit tests of this package
+// do not require a config file or a cluster
+func GetHelperAndConfig() (*testutil.TestHelper, *ConformanceTestOptions) {
+	initOnce.Do(func() {
+		if err := initNewHelperAndConfig(); err != nil {
+			fmt.Printf("failed to initialize test helper or config: %s", err.Error())
+			os.Exit(1)
+		}
+	})
+	return testHelper, testConfig
 }
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestYAMLEqual(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+		actual   string
+		equal    bool
+	}{
+		{
+			name:     "identical documents",
+			expected: "kind: Pod\nmetadata:\n  name: foo\n",
+			actual:   "kind: Pod\nmetadata:\n  name: foo\n",
+			equal:    true,
+		},
+		{
+			name:     "different key order and whitespace",
+			expected: "kind: Pod\nmetadata:\n  name: foo\n  namespace: bar\n",
+			actual:   "metadata:\n    namespace: bar\n    name: foo\nkind: Pod\n",
+			equal:    true,
+		},
+		{
+			name:     "serialization artifacts are ignored",
+			expected: "kind: Deployment\nmetadata:\n  name: foo\nspec:\n  template:\n    metadata:\n      labels:\n        app: foo\n    spec:\n      containers:\n      - name: app\n",
+			actual:   "kind: Deployment\nmetadata:\n  creationTimestamp: null\n  name: foo\nspec:\n  strategy: {}\n  template:\n    metadata:\n      creationTimestamp: null\n      labels:\n        app: foo\n    spec:\n      containers:\n      - name: app\n        resources: {}\nstatus: {}\n",
+			equal:    true,
+		},
+		{
+			name:     "empty annotations are not ignored",
+			expected: "kind: Pod\nmetadata:\n  name: foo\n",
+			actual:   "kind: Pod\nmetadata:\n  annotations: {}\n  name: foo\n",
+			equal:    false,
+		},
+		{
+			name:     "null values are not ignored",
+			expected: "kind: Pod\nmetadata:\n  name: foo\n",
+			actual:   "kind: Pod\nmetadata:\n  name: foo\n  labels: null\n",
+			equal:    false,
+		},
+		{
+			name:     "non-empty resources are not ignored",
+			expected: "containers:\n- name: app\n",
+			actual:   "containers:\n- name: app\n  resources:\n    limits:\n      cpu: 100m\n",
+			equal:    false,
+		},
+		{
+			name:     "different values",
+			expected: "kind: Pod\nmetadata:\n  name: foo\n",
+			actual:   "kind: Pod\nmetadata:\n  name: bar\n",
+			equal:    false,
+		},
+		{
+			name:     "multiple documents",
+			expected: "kind: Service\n---\nkind: Deployment\n",
+			actual:   "---\nkind: Service\n---\nkind: Deployment\n---\n",
+			equal:    true,
+		},
+		{
+			name:     "missing document",
+			expected: "kind: Service\n---\nkind: Deployment\n",
+			actual:   "kind: Service\n",
+			equal:    false,
+		},
+		{
+			name:     "different document order",
+			expected: "kind: Service\n---\nkind: Deployment\n",
+			actual:   "kind: Deployment\n---\nkind: Service\n",
+			equal:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // pin
+		t.Run(tc.name, func(t *testing.T) {
+			equal, err := YAMLEqual(tc.expected, tc.actual)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if equal != tc.equal {
+				t.Fatalf("Expected YAMLEqual to return %t, got %t", tc.equal, equal)
+			}
+		})
+	}
+}
+
+func TestYAMLEqualInvalidYAML(t *testing.T) {
+	if _, err := YAMLEqual("kind: Pod\n", "kind: [Pod\n"); err == nil {
+		t.Fatal("Expected an error for invalid YAML")
+	}
+}
+
+func TestIsStreamTimeout(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{err: nil, expected: false},
+		{err: errors.New("cmd [linkerd tap deploy/client] Timed out trying to read 1 lines"), expected: true},
+		{err: errors.New("Process exited: exit status 1"), expected: false},
+	}
+
+	for _, tc := range testCases {
+		if actual := IsStreamTimeout(tc.err); actual != tc.expected {
+			t.Errorf("Expected IsStreamTimeout(%v) to return %t, got %t", tc.err, tc.expected, actual)
+		}
+	}
+}
+
+func TestNormalizeYAML(t *testing.T) {
+	var in interface{}
+	err := yaml.Unmarshal([]byte(`
+metadata:
+  creationTimestamp: null
+  annotations: {}
+  labels:
+    app: foo
+spec:
+  strategy: {}
+  ports: []
+  containers:
+  - name: app
+    resources: {}
+status: {}
+`), &in)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{},
+			"labels": map[string]interface{}{
+				"app": "foo",
+			},
+		},
+		"spec": map[string]interface{}{
+			"ports": []interface{}{},
+			"containers": []interface{}{
+				map[string]interface{}{
+					"name": "app",
+				},
+			},
+		},
+	}
+
+	if actual := normalizeYAML(in); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("Expected normalized YAML to be %v, got %v", expected, actual)
+	}
+}
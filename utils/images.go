@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const defaultRegistry = "docker.io"
+
+// splitImage splits an image into its registry and repository, following
+// the conventions used by docker - images without a registry are pulled
+// from docker.io, and official images live under the "library" namespace
+func splitImage(image string) (string, string) {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+
+	if len(parts) == 1 {
+		return defaultRegistry, "library/" + image
+	}
+	return defaultRegistry, image
+}
+
+func getMapSliceValue(m yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+func setMapSliceValue(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+// applyImageConfigToContainers resolves the image of every container in the list
+func applyImageConfigToContainers(containers []interface{}, c *ConformanceTestOptions) {
+	for i, container := range containers {
+		container, ok := container.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+
+		if image, ok := getMapSliceValue(container, "image"); ok {
+			if image, ok := image.(string); ok {
+				containers[i] = setMapSliceValue(container, "image", c.ResolveImage(image))
+			}
+		}
+	}
+}
+
+// applyImageConfigToPodSpec resolves the container images of a pod
+// spec and adds the configured image pull secrets, if missing
+func applyImageConfigToPodSpec(spec yaml.MapSlice, c *ConformanceTestOptions) yaml.MapSlice {
+	for _, key := range []string{"initContainers", "containers"} {
+		if containers, ok := getMapSliceValue(spec, key); ok {
+			if containers, ok := containers.([]interface{}); ok {
+				applyImageConfigToContainers(containers, c)
+			}
+		}
+	}
+
+	if len(c.GetImagePullSecrets()) == 0 {
+		return spec
+	}
+
+	secrets := []interface{}{}
+	existing := []string{}
+	if value, ok := getMapSliceValue(spec, "imagePullSecrets"); ok {
+		if value, ok := value.([]interface{}); ok {
+			secrets = value
+		}
+	}
+
+	for _, secret := range secrets {
+		if secret, ok := secret.(yaml.MapSlice); ok {
+			if name, ok := getMapSliceValue(secret, "name"); ok {
+				existing = append(existing, fmt.Sprintf("%v", name))
+			}
+		}
+	}
+
+	for _, name := range c.GetImagePullSecrets() {
+		if indexOf(existing, name) == -1 {
+			secrets = append(secrets, yaml.MapSlice{{Key: "name", Value: name}})
+		}
+	}
+	return setMapSliceValue(spec, "imagePullSecrets", secrets)
+}
+
+// applyImageConfigToNode walks a decoded YAML document
+// and updates every pod spec found in it
+func applyImageConfigToNode(node interface{}, c *ConformanceTestOptions) interface{} {
+	switch v := node.(type) {
+	case yaml.MapSlice:
+		for i, item := range v {
+			v[i].Value = applyImageConfigToNode(item.Value, c)
+		}
+
+		// pod specs are identified by a list of containers
+		if containers, ok := getMapSliceValue(v, "containers"); ok {
+			if _, ok := containers.([]interface{}); ok {
+				return applyImageConfigToPodSpec(v, c)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = applyImageConfigToNode(item, c)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// getImagePullSecrets returns copies of the configured image pull
+// secrets, to be created in the given namespace
+func getImagePullSecrets(namespace string) ([]yaml.MapSlice, error) {
+	h, c := GetHelperAndConfig()
+	secrets := []yaml.MapSlice{}
+
+	if namespace == c.GetImagePullSecretsNamespace() {
+		return secrets, nil
+	}
+
+	for _, name := range c.GetImagePullSecrets() {
+		out, err := h.Kubectl("", "-n", c.GetImagePullSecretsNamespace(), "get", "secret", name, "-o", "yaml")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secret/%s in namespace %s: %s: %s", name, c.GetImagePullSecretsNamespace(), err, out)
+		}
+
+		var secret yaml.MapSlice
+		if err := yaml.Unmarshal([]byte(out), &secret); err != nil {
+			return nil, fmt.Errorf("failed to parse secret/%s: %s", name, err)
+		}
+
+		secretType, _ := getMapSliceValue(secret, "type")
+		data, _ := getMapSliceValue(secret, "data")
+
+		secrets = append(secrets, yaml.MapSlice{
+			{Key: "apiVersion", Value: "v1"},
+			{Key: "kind", Value: "Secret"},
+			{Key: "metadata", Value: yaml.MapSlice{
+				{Key: "name", Value: name},
+				{Key: "namespace", Value: namespace},
+			}},
+			{Key: "type", Value: secretType},
+			{Key: "data", Value: data},
+		})
+	}
+	return secrets, nil
+}
+
+// getNamespaceName returns the name of the namespace
+// declared by a document, if it is a Namespace
+func getNamespaceName(doc yaml.MapSlice) (string, bool) {
+	if kind, _ := getMapSliceValue(doc, "kind"); kind != "Namespace" {
+		return "", false
+	}
+
+	metadata, _ := getMapSliceValue(doc, "metadata")
+	if metadata, ok := metadata.(yaml.MapSlice); ok {
+		if name, ok := getMapSliceValue(metadata, "name"); ok {
+			return fmt.Sprintf("%v", name), true
+		}
+	}
+	return "", false
+}
+
+func writeYAMLDocument(buf *bytes.Buffer, doc interface{}) error {
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %s", err)
+	}
+
+	buf.WriteString("---\n")
+	buf.Write(out)
+	return nil
+}
+
+// CopyImagePullSecrets copies the configured image pull secrets to the
+// given namespace. It must be called for namespaces that are not
+// created through manifests passed to ApplyImageConfig
+func CopyImagePullSecrets(namespace string) error {
+	h, c := GetHelperAndConfig()
+	if len(c.GetImagePullSecrets()) == 0 {
+		return nil
+	}
+
+	secrets, err := getImagePullSecrets(namespace)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, secret := range secrets {
+		if err := writeYAMLDocument(&buf, secret); err != nil {
+			return err
+		}
+	}
+
+	out, err := h.Kubectl(buf.String(), "apply", "-f", "-")
+	if err != nil {
+		return fmt.Errorf("failed to copy image pull secrets to namespace %s: %s: %s", namespace, err, out)
+	}
+	return nil
+}
+
+// ApplyImageConfig rewrites the container images in the given manifest
+// according to the `images` config, and adds the configured image pull
+// secrets to every pod spec. The pull secrets are also copied to every
+// namespace declared in the manifest. The manifest is returned as is
+// when no image overrides or pull secrets are configured
+func ApplyImageConfig(manifest string) (string, error) {
+	_, c := GetHelperAndConfig()
+	if !c.HasImageConfig() {
+		return manifest, nil
+	}
+
+	var buf bytes.Buffer
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc yaml.MapSlice
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse manifest: %s", err)
+		}
+
+		if len(doc) == 0 {
+			continue
+		}
+
+		if err := writeYAMLDocument(&buf, applyImageConfigToNode(doc, c)); err != nil {
+			return "", err
+		}
+
+		if namespace, ok := getNamespaceName(doc); ok && len(c.GetImagePullSecrets()) > 0 {
+			secrets, err := getImagePullSecrets(namespace)
+			if err != nil {
+				return "", err
+			}
+
+			for _, secret := range secrets {
+				if err := writeYAMLDocument(&buf, secret); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	return buf.String(), nil
+}
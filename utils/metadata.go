@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2-conformance/report"
+	"github.com/linkerd/linkerd2/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	unknown = "unknown"
+
+	featureGatesFlag = "--feature-gates="
+)
+
+// runID identifies the current run. When running in parallel, it is generated
+// by the first Ginkgo node and shared with the other ones through SetRunID
+var runID = fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405Z"), randomSuffix(8))
+
+// GetRunID returns the ID of the current run
+func GetRunID() string {
+	return runID
+}
+
+// SetRunID sets the ID of the current run, so that the reports
+// written by all the Ginkgo nodes of a parallel run share it
+func SetRunID(id string) {
+	runID = id
+}
+
+// cniDaemonSets maps the prefixes of the names of the
+// DaemonSets deployed by CNI plugins to the plugin name
+var cniDaemonSets = map[string]string{
+	"calico-node":  "calico",
+	"canal":        "canal",
+	"cilium":       "cilium",
+	"weave-net":    "weave",
+	"kube-flannel": "flannel",
+	"aws-node":     "aws-vpc-cni",
+	"kindnet":      "kindnet",
+	"antrea-agent": "antrea",
+	"kube-router":  "kube-router",
+}
+
+func getNodes(h *testutil.TestHelper) ([]corev1.Node, error) {
+	out, err := h.Kubectl("", "get", "nodes", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %s: %s", err, out)
+	}
+
+	var nodes corev1.NodeList
+	if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal nodes: %s", err)
+	}
+	return nodes.Items, nil
+}
+
+// detectCloudProvider returns the scheme of the provider ID of the nodes, such as "aws" or "gce"
+func detectCloudProvider(nodes []corev1.Node) string {
+	for _, node := range nodes {
+		if i := strings.Index(node.Spec.ProviderID, "://"); i > 0 {
+			return node.Spec.ProviderID[:i]
+		}
+	}
+	return unknown
+}
+
+// detectCNI returns the CNI plugins whose DaemonSets are running in the cluster
+func detectCNI(h *testutil.TestHelper) (string, error) {
+	out, err := h.Kubectl("", "get", "daemonsets", "--all-namespaces", "-o", "json")
+	if err != nil {
+		return "", fmt.Errorf("failed to get DaemonSets: %s: %s", err, out)
+	}
+
+	var daemonSets appsv1.DaemonSetList
+	if err := json.Unmarshal([]byte(out), &daemonSets); err != nil {
+		return "", fmt.Errorf("failed to unmarshal DaemonSets: %s", err)
+	}
+
+	plugins := []string{}
+	for _, ds := range daemonSets.Items {
+		for prefix, plugin := range cniDaemonSets {
+			if strings.HasPrefix(ds.GetName(), prefix) && indexOf(plugins, plugin) < 0 {
+				plugins = append(plugins, plugin)
+			}
+		}
+	}
+
+	if len(plugins) == 0 {
+		return unknown, nil
+	}
+	return strings.Join(plugins, ", "), nil
+}
+
+// detectFeatureGates returns the feature gates of the API server. They can only
+// be read when the API server runs as a pod, which is not the case on most
+// managed clusters, in which case an empty string is returned
+func detectFeatureGates(h *testutil.TestHelper) string {
+	out, err := h.Kubectl("", "-n", "kube-system", "get", "pods", "-l", "component=kube-apiserver", "-o", "json")
+	if err != nil {
+		return ""
+	}
+
+	var pods corev1.PodList
+	if err := json.Unmarshal([]byte(out), &pods); err != nil {
+		return ""
+	}
+
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			for _, arg := range append(container.Command, container.Args...) {
+				if strings.HasPrefix(arg, featureGatesFlag) {
+					return strings.TrimPrefix(arg, featureGatesFlag)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// CollectRunMetadata describes the cluster and the control plane the specs run against.
+// It must be called after RunPreflight, whose server version it reuses
+func CollectRunMetadata(caps *Capabilities) (*report.Metadata, error) {
+	h, c := GetHelperAndConfig()
+
+	nodes, err := getNodes(h)
+	if err != nil {
+		return nil, err
+	}
+
+	cni, err := detectCNI(h)
+	if err != nil {
+		return nil, err
+	}
+
+	// the run ID is set by the RunReporter, as it is not
+	// shared by the Ginkgo nodes until the suite starts
+	m := &report.Metadata{
+		StartedAt:         time.Now().UTC(),
+		LinkerdVersion:    h.GetVersion(),
+		KubernetesVersion: caps.ServerVersion,
+		FeatureGates:      detectFeatureGates(h),
+		CloudProvider:     detectCloudProvider(nodes),
+		CNI:               cni,
+		LinkerdCNI:        c.CNIEnabled(),
+		HA:                c.HA(),
+	}
+
+	for _, node := range nodes {
+		info := node.Status.NodeInfo
+		m.Nodes = append(m.Nodes, report.NodeMetadata{
+			Name:             node.GetName(),
+			OSImage:          info.OSImage,
+			KernelVersion:    info.KernelVersion,
+			ContainerRuntime: info.ContainerRuntimeVersion,
+			KubeletVersion:   info.KubeletVersion,
+			Architecture:     info.Architecture,
+		})
+	}
+	return m, nil
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+// ProxyMetric is a sample of a metric exposed by a proxy
+type ProxyMetric struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// GetProxyMetrics returns the samples of the given counter exposed
+// on the `/metrics` endpoint of the proxy admin server at adminURL
+func GetProxyMetrics(adminURL, name string) ([]ProxyMetric, error) {
+	h, _ := GetHelperAndConfig()
+
+	body, err := h.HTTPGetURL(adminURL + "/metrics")
+	if err != nil {
+		return nil, err
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proxy metrics: %s", err)
+	}
+
+	metrics := []ProxyMetric{}
+	family, ok := families[name]
+	if !ok {
+		return metrics, nil
+	}
+
+	for _, m := range family.GetMetric() {
+		labels := map[string]string{}
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		metrics = append(metrics, ProxyMetric{Labels: labels, Value: m.GetCounter().GetValue()})
+	}
+	return metrics, nil
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/gomega"
+)
+
+var (
+	// namespaceSuffix is appended to the namespaces requested through
+	// GetSpecNamespace, and is unique to each Ginkgo node
+	namespaceSuffix = randomSuffix(5)
+
+	lockFile *os.File
+
+	// controlPlaneSkipReason is set when the control plane is not installed as
+	// the cluster does not support it, so that the specs requiring it are skipped
+	controlPlaneSkipReason string
+)
+
+func randomSuffix(n int) string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(os.Getpid())))
+
+	suffix := make([]byte, n)
+	for i := range suffix {
+		suffix[i] = chars[r.Intn(len(chars))]
+	}
+	return string(suffix)
+}
+
+// Parallel determines if the specs are being run on multiple Ginkgo nodes
+func Parallel() bool {
+	return config.GinkgoConfig.ParallelTotal > 1
+}
+
+// GetSpecNamespace returns the namespace to be used by a spec for the given
+// name. The namespace is suffixed with an ID unique to the Ginkgo node, so that
+// specs running in parallel (or leftovers from previous runs) do not interfere
+func GetSpecNamespace(name string) string {
+	h, _ := GetHelperAndConfig()
+	return h.GetTestNamespace(fmt.Sprintf("%s-%s", name, namespaceSuffix))
+}
+
+func getLockFile() (*os.File, error) {
+	if lockFile != nil {
+		return lockFile, nil
+	}
+
+	h, _ := GetHelperAndConfig()
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("linkerd2-conformance-%s.lock", h.GetLinkerdNamespace()))
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %s", path, err)
+	}
+
+	lockFile = f
+	return lockFile, nil
+}
+
+// AcquireNodeLock acquires a lock shared by all the Ginkgo nodes of a run.
+// Any number of nodes can hold the lock in shared mode, while a node holding
+// it in exclusive mode prevents all the other nodes from acquiring it
+func AcquireNodeLock(exclusive bool) error {
+	f, err := getLockFile()
+	if err != nil {
+		return err
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		return fmt.Errorf("failed to acquire lock %s: %s", f.Name(), err)
+	}
+	return nil
+}
+
+// ReleaseNodeLock releases the lock acquired through AcquireNodeLock
+func ReleaseNodeLock() error {
+	f, err := getLockFile()
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+		return fmt.Errorf("failed to release lock %s: %s", f.Name(), err)
+	}
+	return nil
+}
+
+// RequireControlPlane is called within a Describe block to declare whether its
+// specs need exclusive access to the control plane (such as specs that scale
+// down or restart control plane components). When the specs run in parallel,
+// exclusive specs wait for all the other specs to complete before running,
+// and no other spec runs until they complete. The resources tracked by a spec
+// are deleted before the lock is released. The specs are skipped if the
+// control plane could not be installed as the cluster does not support it
+func RequireControlPlane(exclusive bool) {
+	_ = ginkgo.BeforeEach(func() {
+		if controlPlaneSkipReason != "" {
+			ginkgo.Skip(fmt.Sprintf("Skipping as the control plane could not be installed: %s", controlPlaneSkipReason))
+		}
+	})
+
+	if !Parallel() {
+		return
+	}
+
+	_ = ginkgo.BeforeEach(func() {
+		err := AcquireNodeLock(exclusive)
+		gomega.Expect(err).Should(gomega.BeNil(), Err(err))
+	})
+
+	// the AfterEach blocks of the enclosing Describe blocks run after this one,
+	// hence the resources of the spec are deleted here while the lock is held
+	_ = ginkgo.AfterEach(func() {
+		CleanupSpecResources()
+
+		err := ReleaseNodeLock()
+		gomega.Expect(err).Should(gomega.BeNil(), Err(err))
+	})
+}
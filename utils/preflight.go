@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linkerd/linkerd2/testutil"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	loadBalancerProbePath    = "testdata/preflight/loadbalancer.yaml"
+	loadBalancerProbeName    = "preflight-lb"
+	loadBalancerProbeTimeout = 2 * time.Minute
+
+	// minHANodes is the number of nodes required by the anti-affinity
+	// rules of a high-availability control plane
+	minHANodes = HAReplicas
+)
+
+// installPermissions holds the cluster-wide resources that must be
+// created by `linkerd install`, as passed to `kubectl auth can-i create`
+var installPermissions = []string{
+	"namespaces",
+	"clusterroles.rbac.authorization.k8s.io",
+	"clusterrolebindings.rbac.authorization.k8s.io",
+	"customresourcedefinitions.apiextensions.k8s.io",
+	"mutatingwebhookconfigurations.admissionregistration.k8s.io",
+	"validatingwebhookconfigurations.admissionregistration.k8s.io",
+	"apiservices.apiregistration.k8s.io",
+}
+
+// pspPermission is only required when the cluster serves PodSecurityPolicies
+const pspPermission = "podsecuritypolicies.policy"
+
+// Capabilities holds the capabilities of the cluster,
+// detected before any of the specs are run
+type Capabilities struct {
+	ServerVersion string
+	Major         int
+	Minor         int
+
+	// SchedulableNodes is the number of nodes on which workloads can be scheduled
+	SchedulableNodes int
+
+	// LoadBalancer is set if services of type LoadBalancer are assigned an address.
+	// It is only probed when the ingress tests use the loadBalancer reachability strategy
+	LoadBalancer       bool
+	LoadBalancerProbed bool
+
+	// MissingInstallPermissions holds the resources that `linkerd install`
+	// must create, but that the current user is not allowed to create
+	MissingInstallPermissions []string
+
+	APIVersions []string
+}
+
+var capabilities *Capabilities
+
+// serverVersion is used for unmarshalling the output of `kubectl version -o json`
+type serverVersion struct {
+	ServerVersion struct {
+		Major      string `json:"major"`
+		Minor      string `json:"minor"`
+		GitVersion string `json:"gitVersion"`
+	} `json:"serverVersion"`
+}
+
+// parseVersionNumber parses version numbers such as "18+", as reported by some providers
+func parseVersionNumber(v string) (int, error) {
+	return strconv.Atoi(strings.TrimRight(v, "+"))
+}
+
+func detectServerVersion(h *testutil.TestHelper, caps *Capabilities) error {
+	out, err := h.Kubectl("", "version", "-o", "json")
+	if err != nil {
+		return fmt.Errorf("failed to get server version: %s: %s", err, out)
+	}
+
+	var version serverVersion
+	if err := json.Unmarshal([]byte(out), &version); err != nil {
+		return fmt.Errorf("failed to unmarshal server version: %s", err)
+	}
+
+	if caps.Major, err = parseVersionNumber(version.ServerVersion.Major); err != nil {
+		return fmt.Errorf("invalid server major version %q: %s", version.ServerVersion.Major, err)
+	}
+
+	if caps.Minor, err = parseVersionNumber(version.ServerVersion.Minor); err != nil {
+		return fmt.Errorf("invalid server minor version %q: %s", version.ServerVersion.Minor, err)
+	}
+
+	caps.ServerVersion = version.ServerVersion.GitVersion
+	return nil
+}
+
+func isSchedulable(node corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return false
+		}
+	}
+	return true
+}
+
+func detectNodes(h *testutil.TestHelper, caps *Capabilities) error {
+	nodes, err := getNodes(h)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if isSchedulable(node) {
+			caps.SchedulableNodes++
+		}
+	}
+	return nil
+}
+
+func detectAPIVersions(h *testutil.TestHelper, caps *Capabilities) error {
+	out, err := h.Kubectl("", "api-versions")
+	if err != nil {
+		return fmt.Errorf("failed to fetch API versions: %s: %s", err, out)
+	}
+
+	caps.APIVersions = strings.Fields(out)
+	return nil
+}
+
+func canCreate(h *testutil.TestHelper, resource string) bool {
+	out, _ := h.Kubectl("", "auth", "can-i", "create", resource)
+	return strings.TrimSpace(out) == "yes"
+}
+
+func detectInstallPermissions(h *testutil.TestHelper, caps *Capabilities) error {
+	permissions := append([]string{}, installPermissions...)
+
+	servesPSP, err := SupportsResource(h, "policy/v1beta1", "podsecuritypolicies")
+	if err != nil {
+		return err
+	}
+	if servesPSP {
+		permissions = append(permissions, pspPermission)
+	}
+
+	for _, resource := range permissions {
+		if !canCreate(h, resource) {
+			caps.MissingInstallPermissions = append(caps.MissingInstallPermissions, resource)
+		}
+	}
+	return nil
+}
+
+// loadBalancerProbeValues holds the values used for rendering loadBalancerProbePath
+type loadBalancerProbeValues struct {
+	TemplateValues
+	Name      string
+	Namespace string
+}
+
+// detectLoadBalancer creates a service of type LoadBalancer
+// and waits for it to be assigned an address
+func detectLoadBalancer(h *testutil.TestHelper, caps *Capabilities) error {
+	ns := GetSpecNamespace("preflight")
+	if err := h.CreateDataPlaneNamespaceIfNotExists(ns, nil); err != nil {
+		return fmt.Errorf("failed to create namespace %s: %s", ns, err)
+	}
+	defer h.Kubectl("", "delete", "ns", ns, "--ignore-not-found", "--wait=false")
+
+	svc, err := RenderTemplate(loadBalancerProbePath, loadBalancerProbeValues{
+		TemplateValues: GetTemplateValues(),
+		Name:           loadBalancerProbeName,
+		Namespace:      ns,
+	})
+	if err != nil {
+		return err
+	}
+
+	if out, err := h.Kubectl(svc, "apply", "-f", "-"); err != nil {
+		return fmt.Errorf("failed to create svc/%s: %s: %s", loadBalancerProbeName, err, out)
+	}
+
+	err = h.RetryFor(loadBalancerProbeTimeout, func() error {
+		out, err := h.Kubectl("", "-n", ns, "get", "svc", loadBalancerProbeName, "-o", "jsonpath={.status.loadBalancer.ingress}")
+		if err != nil {
+			return fmt.Errorf("failed to get svc/%s: %s: %s", loadBalancerProbeName, err, out)
+		}
+		if strings.TrimSpace(out) == "" {
+			return fmt.Errorf("svc/%s has not been assigned an address", loadBalancerProbeName)
+		}
+		return nil
+	})
+
+	caps.LoadBalancer = err == nil
+	caps.LoadBalancerProbed = true
+	return nil
+}
+
+// RunPreflight detects the capabilities of the cluster. It must be called
+// before the specs are declared, so that they can be skipped (with an
+// explicit reason) when the cluster lacks their prerequisites
+func RunPreflight() (*Capabilities, error) {
+	h, c := GetHelperAndConfig()
+	caps := &Capabilities{}
+
+	detectors := []func(*testutil.TestHelper, *Capabilities) error{
+		detectServerVersion,
+		detectNodes,
+		detectAPIVersions,
+		detectInstallPermissions,
+	}
+
+	if !c.SkipIngress() && c.GetIngressReachability() == ReachabilityLoadBalancer {
+		detectors = append(detectors, detectLoadBalancer)
+	}
+
+	for _, detect := range detectors {
+		if err := detect(h, caps); err != nil {
+			return nil, fmt.Errorf("preflight failed: %s", err)
+		}
+	}
+
+	capabilities = caps
+	return caps, nil
+}
+
+// GetCapabilities returns the capabilities detected by RunPreflight
+func GetCapabilities() *Capabilities {
+	return capabilities
+}
+
+// SupportsAPI checks if the cluster serves the given group version
+func (caps *Capabilities) SupportsAPI(groupVersion string) bool {
+	return indexOf(caps.APIVersions, groupVersion) > -1
+}
+
+// ControlPlaneUnsupportedReason returns the reason for which the control
+// plane cannot be installed, or an empty string if it can
+func (caps *Capabilities) ControlPlaneUnsupportedReason(ha bool) string {
+	if len(caps.MissingInstallPermissions) > 0 {
+		return fmt.Sprintf("the current user is not allowed to create %s, which are required by `linkerd install`", strings.Join(caps.MissingInstallPermissions, ", "))
+	}
+
+	if ha && caps.SchedulableNodes < minHANodes {
+		return fmt.Sprintf("a high-availability control plane requires at least %d schedulable nodes, found %d", minHANodes, caps.SchedulableNodes)
+	}
+	return ""
+}
+
+// LoadBalancerUnsupportedReason returns the reason for which services of type
+// LoadBalancer cannot be used, or an empty string if they can
+func (caps *Capabilities) LoadBalancerUnsupportedReason() string {
+	if caps.LoadBalancerProbed && !caps.LoadBalancer {
+		return fmt.Sprintf("services of type LoadBalancer are not assigned an address within %s - consider using another reachability strategy", loadBalancerProbeTimeout)
+	}
+	return ""
+}
+
+// String returns a summary of the capabilities, printed before the specs run
+func (caps *Capabilities) String() string {
+	lb := "not probed"
+	if caps.LoadBalancerProbed {
+		lb = strconv.FormatBool(caps.LoadBalancer)
+	}
+
+	missing := "none"
+	if len(caps.MissingInstallPermissions) > 0 {
+		missing = strings.Join(caps.MissingInstallPermissions, ", ")
+	}
+
+	return fmt.Sprintf(`Cluster capabilities:
+  Server version: %s
+  Schedulable nodes: %d
+  LoadBalancer services: %s
+  Missing install permissions: %s
+`, caps.ServerVersion, caps.SchedulableNodes, lb, missing)
+}
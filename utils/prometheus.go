@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+// externalPrometheusValues holds the values used for rendering `testdata/prometheus/external.yaml`
+type externalPrometheusValues struct {
+	TemplateValues
+	Namespace string
+}
+
+func renderExternalPrometheus() string {
+	_, c := GetHelperAndConfig()
+
+	manifest, err := RenderTemplate(externalPrometheusManifest, externalPrometheusValues{
+		TemplateValues: GetTemplateValues(),
+		Namespace:      c.GetExternalPrometheusNamespace(),
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), Err(err))
+
+	manifest, err = ApplyImageConfig(manifest)
+	gomega.Expect(err).Should(gomega.BeNil(), Err(err))
+	return manifest
+}
+
+// installExternalPrometheus installs the Prometheus used by the control
+// plane when `controlPlane.config.externalPrometheus` is set
+func installExternalPrometheus() {
+	h, c := GetHelperAndConfig()
+	ns := c.GetExternalPrometheusNamespace()
+
+	ginkgo.By(fmt.Sprintf("Installing external Prometheus in namespace %s", ns))
+	out, err := h.KubectlApply(renderExternalPrometheus(), "")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to install external Prometheus: %s: %s", Err(err), out))
+
+	out, err = h.Kubectl("", "-n", ns, "rollout", "status", "--timeout=180s", "deploy/"+ExternalPrometheusDeploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("external Prometheus did not become ready: %s: %s", Err(err), out))
+}
+
+// uninstallExternalPrometheus deletes the resources created by installExternalPrometheus
+func uninstallExternalPrometheus() {
+	h, _ := GetHelperAndConfig()
+
+	ginkgo.By("Uninstalling external Prometheus")
+	out, err := h.Kubectl(renderExternalPrometheus(), "delete", "--ignore-not-found", "-f", "-")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to uninstall external Prometheus: %s: %s", Err(err), out))
+}
+
+// checkBundledPrometheusDisabled checks that the control plane manifests do not include
+// the bundled Prometheus, which is the case for Linkerd versions that cannot disable it
+func checkBundledPrometheusDisabled(manifest string) error {
+	h, _ := GetHelperAndConfig()
+
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc yaml.MapSlice
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to parse control plane manifests: %s", err)
+		}
+
+		kind, _ := getMapSliceValue(doc, "kind")
+		metadata, _ := getMapSliceValue(doc, "metadata")
+		m, _ := metadata.(yaml.MapSlice)
+		name, _ := getMapSliceValue(m, "name")
+
+		if kind == "Deployment" && name == PrometheusDeploy {
+			return fmt.Errorf("Linkerd %s does not support disabling the bundled Prometheus, hence 'controlPlane.config.externalPrometheus' cannot be used", h.GetVersion())
+		}
+	}
+}
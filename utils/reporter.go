@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/linkerd/linkerd2-conformance/report"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+// RunReporter is a Ginkgo reporter writing the metadata
+// and the results of the run to a JSON report
+type RunReporter struct {
+	path   string
+	report report.RunReport
+}
+
+// NewRunReporter returns a reporter writing the run report to path
+func NewRunReporter(path string, metadata report.Metadata) *RunReporter {
+	return &RunReporter{
+		path:   path,
+		report: report.RunReport{Metadata: metadata},
+	}
+}
+
+func (r *RunReporter) handleSetupSummary(name string, summary *types.SetupSummary) {
+	if summary.State.IsFailure() {
+		r.report.Results.FailedSpecs = append(r.report.Results.FailedSpecs, name)
+	}
+}
+
+// SpecSuiteWillBegin implements the Ginkgo Reporter interface
+func (r *RunReporter) SpecSuiteWillBegin(config.GinkgoConfigType, *types.SuiteSummary) {}
+
+// BeforeSuiteDidRun implements the Ginkgo Reporter interface
+func (r *RunReporter) BeforeSuiteDidRun(summary *types.SetupSummary) {
+	r.handleSetupSummary("BeforeSuite", summary)
+}
+
+// SpecWillRun implements the Ginkgo Reporter interface
+func (r *RunReporter) SpecWillRun(*types.SpecSummary) {}
+
+// SpecDidComplete implements the Ginkgo Reporter interface
+func (r *RunReporter) SpecDidComplete(summary *types.SpecSummary) {
+	if summary.HasFailureState() {
+		name := strings.TrimSpace(strings.Join(summary.ComponentTexts[1:], " "))
+		r.report.Results.FailedSpecs = append(r.report.Results.FailedSpecs, name)
+	}
+}
+
+// AfterSuiteDidRun implements the Ginkgo Reporter interface
+func (r *RunReporter) AfterSuiteDidRun(summary *types.SetupSummary) {
+	r.handleSetupSummary("AfterSuite", summary)
+}
+
+// SpecSuiteDidEnd implements the Ginkgo Reporter interface
+func (r *RunReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	r.report.Metadata.RunID = GetRunID()
+	r.report.Results.Succeeded = summary.SuiteSucceeded
+	r.report.Results.Passed = summary.NumberOfPassedSpecs
+	r.report.Results.Failed = summary.NumberOfFailedSpecs
+	r.report.Results.Skipped = summary.NumberOfSkippedSpecs + summary.NumberOfPendingSpecs
+	r.report.Results.RunTime = summary.RunTime.String()
+
+	if err := report.Write(r.path, r.report); err != nil {
+		fmt.Fprintf(os.Stderr, "\nFailed to write the run report %s: %s\n", r.path, err)
+		return
+	}
+	fmt.Printf("\nRun report was created: %s\n", r.path)
+}
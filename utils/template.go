@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// TemplateImages holds the images used by the test workloads
+type TemplateImages struct {
+	BB         string
+	EmojiSvc   string
+	VotingSvc  string
+	Web        string
+	Curl       string
+	Prometheus string
+}
+
+// TemplateValues holds the values available to the manifests under
+// `testdata`, which are rendered as Go templates before being used
+type TemplateValues struct {
+	ClusterDomain    string
+	LinkerdNamespace string
+	Images           TemplateImages
+}
+
+// GetTemplateValues returns the template values derived from the test configuration
+func GetTemplateValues() TemplateValues {
+	h, _ := GetHelperAndConfig()
+
+	return TemplateValues{
+		ClusterDomain:    h.GetClusterDomain(),
+		LinkerdNamespace: h.GetLinkerdNamespace(),
+		Images: TemplateImages{
+			BB:         bbImage,
+			EmojiSvc:   emojiSvcImage,
+			VotingSvc:  votingSvcImage,
+			Web:        webImage,
+			Curl:       CurlImage,
+			Prometheus: prometheusImage,
+		},
+	}
+}
+
+// RenderTemplate renders the template at path with the given values.
+// values must embed TemplateValues for the template to be able to
+// access the common values
+func RenderTemplate(path string, values interface{}) (string, error) {
+	tmpl, err := template.ParseFiles(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %s", path, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %s", path, err)
+	}
+	return buf.String(), nil
+}
+
+// RenderManifest renders the manifest at path with the values
+// derived from the test configuration, and applies the image
+// overrides and pull secrets from the `images` config
+func RenderManifest(path string) (string, error) {
+	manifest, err := RenderTemplate(path, GetTemplateValues())
+	if err != nil {
+		return "", err
+	}
+	return ApplyImageConfig(manifest)
+}
@@ -3,11 +3,14 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/linkerd/linkerd2/pkg/k8s"
 	"github.com/linkerd/linkerd2/testutil"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 )
 
 var (
@@ -20,59 +23,39 @@ var (
 		"linkerd-web",
 		"linkerd-tap",
 	}
-)
-
-// CheckOutput is used for unmarshalling the
-// output from `linkerd check -o json`
-type CheckOutput struct {
-	Success    bool `json:"success"`
-	Categories []struct {
-		CategoryName string `json:"categoryName"`
-		Checks       []struct {
-			Result string `json:"result"`
-			Error  string `json:"error"`
-		}
-	}
-}
-
-func getFailedChecks(r *CheckOutput) string {
-	err := "The following errors were detected:\n"
 
-	for _, c := range r.Categories {
-		for _, check := range c.Checks {
-			if check.Result == "error" {
-				err = fmt.Sprintf("%s\n%s", err, check.Error)
-			}
-		}
+	// tracingDeploys are the deployments (and services) of the tracing add-on
+	tracingDeploys = []string{CollectorDeploy, JaegerDeploy}
+
+	// HADeploys maps the control plane deployments that are
+	// replicated in high-availability mode to their main container
+	HADeploys = map[string]string{
+		"linkerd-controller":     "public-api",
+		"linkerd-destination":    "destination",
+		"linkerd-identity":       "identity",
+		"linkerd-proxy-injector": "proxy-injector",
+		"linkerd-sp-validator":   "sp-validator",
+		"linkerd-tap":            "tap",
 	}
+)
 
-	return err
-}
-
-// RunCheck rus `linkerd check`
-func RunCheck(h *testutil.TestHelper, pre bool) {
-
-	var checkResult *CheckOutput
-
-	cmd := []string{
-		"check",
-		"-o",
-		"json",
+// getAddOnConfigFlags writes the add-on config to a file and returns the flags
+// passing it to `linkerd install`. The file is written every time, so that it
+// never holds the add-on config of a previous run
+func getAddOnConfigFlags(c *ConformanceTestOptions) []string {
+	if !c.HasAddOnConfig() {
+		return nil
 	}
 
-	if pre {
-		cmd = append(cmd, "--pre")
-		ginkgo.By("Running pre-installation checks")
-	} else {
-		ginkgo.By("Running post-installation checks")
-	}
+	addOnFile := "../../addons.yaml"
+	out, err := c.GetAddOnsYAML()
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to produce add-on config file: %s", Err(err)))
 
-	out, _, _ := h.LinkerdRun(cmd...)
+	err = createFileWithContent(out, addOnFile)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to write add-ons to YAML: %s", Err(err)))
 
-	ginkgo.By("Validating `check` output")
-	err := json.Unmarshal([]byte(out), &checkResult)
-	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to unmarshal check results JSON: %s", Err(err)))
-	gomega.Expect(checkResult.Success).Should(gomega.BeTrue(), fmt.Sprintf("`linkerd check failed: %s`\n Check errors: %s", Err(err), getFailedChecks(checkResult)))
+	ginkgo.By(fmt.Sprintf("Using add-ons file %s", addOnFile))
+	return []string{"--addon-config", addOnFile}
 }
 
 // InstallLinkerdControlPlane runs the control plane install tests
@@ -80,12 +63,21 @@ func InstallLinkerdControlPlane(h *testutil.TestHelper, c *ConformanceTestOption
 	withHA := c.HA()
 
 	ginkgo.By(fmt.Sprintf("Installing linkerd control plane with HA: %v", withHA))
-	RunCheck(h, true) // run pre checks
-
 	if err := h.CheckIfNamespaceExists(h.GetLinkerdNamespace()); err == nil {
 		ginkgo.Skip(fmt.Sprintf("linkerd control plane already exists in namespace %s", h.GetLinkerdNamespace()))
 	}
 
+	if reason := GetCapabilities().ControlPlaneUnsupportedReason(withHA); reason != "" {
+		controlPlaneSkipReason = reason
+		ginkgo.Skip(reason)
+	}
+
+	if c.CNIEnabled() {
+		installCNI()
+	}
+
+	RunCheck(h, true) // run pre checks
+
 	// TODO: Uncomment while writing Helm tests
 	// ginkgo.By("verifying if Helm release is empty")
 	// gomega.Expect(h.GetHelmReleaseName()).To(gomega.Equal(""))
@@ -98,36 +90,38 @@ func InstallLinkerdControlPlane(h *testutil.TestHelper, c *ConformanceTestOption
 		args = append(args, flag)
 	}
 
-	if len(c.GetAddons()) > 0 {
-
-		addOnFile := "../../addons.yaml"
-		if !fileExists(addOnFile) {
-			out, err := c.GetAddOnsYAML()
-			gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to produce add-on config file: %s", Err(err)))
-
-			err = createFileWithContent(out, addOnFile)
-			gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to write add-ons to YAML: %s", Err(err)))
-		}
-
-		ginkgo.By(fmt.Sprintf("Using add-ons file %s", addOnFile))
-		args = append(args, "--addon-config")
-		args = append(args, addOnFile)
-	}
+	args = append(args, getAddOnConfigFlags(c)...)
 
 	if withHA {
 		args = append(args, "--ha")
 	}
 
+	if c.CNIEnabled() {
+		args = append(args, "--linkerd-cni-enabled")
+	}
+
 	if h.GetClusterDomain() != "cluster.local" {
 		args = append(args, "--cluster-domain", h.GetClusterDomain())
 	}
 
+	args = append(args, c.GetInstallImageFlags()...)
+
 	exec := append([]string{cmd}, args...)
 
 	ginkgo.By("Running `linkerd install`")
 	out, stderr, err := h.LinkerdRun(exec...)
 	gomega.Expect(err).Should(gomega.BeNil(), stderr)
 
+	if c.ExternalPrometheus() {
+		err = checkBundledPrometheusDisabled(out)
+		gomega.Expect(err).Should(gomega.BeNil(), Err(err))
+
+		installExternalPrometheus()
+	}
+
+	out, err = ApplyImageConfig(out)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply image config to control plane manifests: %s", Err(err)))
+
 	ginkgo.By("Applying control plane manifests")
 	out, err = h.KubectlApply(out, "")
 	gomega.Expect(err).Should(gomega.BeNil(), Err(err))
@@ -139,25 +133,40 @@ func InstallLinkerdControlPlane(h *testutil.TestHelper, c *ConformanceTestOption
 // UninstallLinkerdControlPlane runs the test for
 // control plane uninstall
 func UninstallLinkerdControlPlane(h *testutil.TestHelper) {
+	_, c := GetHelperAndConfig()
+
 	ginkgo.By("Uninstalling linkerd control plane")
 	cmd := "install"
 	args := []string{
 		"--ignore-cluster",
 	}
 
+	// the manifests are rendered with the same add-ons as the installed control
+	// plane, so that enabled add-ons are deleted along with the control plane
+	args = append(args, getAddOnConfigFlags(c)...)
+
 	exec := append([]string{cmd}, args...)
 
 	ginkgo.By("Gathering control plane manifests")
 	out, stderr, err := h.LinkerdRun(exec...)
 	gomega.Expect(err).Should(gomega.BeNil(), stderr)
 
-	args = []string{"delete", "-f", "-"}
+	args = []string{"delete", "--ignore-not-found", "-f", "-"}
 
 	ginkgo.By("Deleting resources from the cluster")
 	out, err = h.Kubectl(out, args...)
 	gomega.Expect(err).Should(gomega.BeNil(), Err(err))
 
+	if c.ExternalPrometheus() {
+		uninstallExternalPrometheus()
+	}
+
 	RunCheck(h, true) // run pre checks
+
+	// the pre checks validate the linkerd-cni plugin, hence it is uninstalled last
+	if c.CNIEnabled() {
+		uninstallCNI()
+	}
 }
 
 func testResourcesPostInstall(namespace string, services []string, deploys map[string]testutil.DeploySpec, h *testutil.TestHelper) {
@@ -184,9 +193,51 @@ func testResourcesPostInstall(namespace string, services []string, deploys map[s
 	}
 }
 
+// GetControlPlaneReplicas returns the deployments of the control
+// plane along with the number of replicas they are expected to have
+func GetControlPlaneReplicas(ha bool) map[string]testutil.DeploySpec {
+	_, c := GetHelperAndConfig()
+	deploys := map[string]testutil.DeploySpec{}
+	for deploy, spec := range testutil.LinkerdDeployReplicas {
+		if !c.IsComponentEnabled(deploy) {
+			continue
+		}
+
+		if _, ok := HADeploys[deploy]; ok && ha {
+			spec.Replicas = HAReplicas
+		}
+		deploys[deploy] = spec
+	}
+
+	if c.IsAddOnEnabled(Tracing) {
+		for _, deploy := range tracingDeploys {
+			deploys[deploy] = testutil.DeploySpec{Replicas: 1}
+		}
+	}
+	return deploys
+}
+
+// getControlPlaneServices returns the services of the control plane
+func getControlPlaneServices() []string {
+	_, c := GetHelperAndConfig()
+	svcs := []string{}
+	for _, svc := range linkerdSvcs {
+		if !c.IsComponentEnabled(svc) {
+			continue
+		}
+		svcs = append(svcs, svc)
+	}
+
+	if c.IsAddOnEnabled(Tracing) {
+		svcs = append(svcs, tracingDeploys...)
+	}
+	return svcs
+}
+
 // TestControlPlanePostInstall tests the control plane resources post installation
 func TestControlPlanePostInstall(h *testutil.TestHelper) {
-	testResourcesPostInstall(h.GetLinkerdNamespace(), linkerdSvcs, testutil.LinkerdDeployReplicas, h)
+	_, c := GetHelperAndConfig()
+	testResourcesPostInstall(h.GetLinkerdNamespace(), getControlPlaneServices(), GetControlPlaneReplicas(c.HA()), h)
 }
 
 // RunBeforeAndAfterEachSetup runs the control plane installation
@@ -205,69 +256,129 @@ func RunBeforeAndAfterEachSetup() {
 }
 
 var (
-	emojivotoNs      = "emojivoto"
-	emojivotoDeploys = []string{"emoji", "voting", "web"}
+	// EmojivotoDeploys holds the names of the emojivoto deployments
+	EmojivotoDeploys = []string{"emoji", "voting", "web"}
+
+	// emojivotoInstalled holds the namespaces in which
+	// emojivoto has been installed by the current Ginkgo node
+	emojivotoInstalled = map[string]bool{}
 )
 
-func checkSampleAppState() {
+// emojivotoValues holds the values used for rendering `testdata/emojivoto.yml`
+type emojivotoValues struct {
+	TemplateValues
+	Namespace      string
+	TraceCollector string // address of the OpenCensus collector the app sends spans to, if any
+}
+
+func checkSampleAppState(ns string) {
 	h, _ := GetHelperAndConfig()
-	for _, deploy := range emojivotoDeploys {
-		if err := h.CheckPods(emojivotoNs, deploy, 1); err != nil {
+	for _, deploy := range EmojivotoDeploys {
+		if err := h.CheckPods(ns, deploy, 1); err != nil {
 			if _, ok := err.(*testutil.RestartCountError); !ok { // err is not due to restart
 				ginkgo.Fail(fmt.Sprintf("failed to validate emojivoto pods: %s", err.Error()))
 			}
 		}
 
-		err := h.CheckDeployment(emojivotoNs, deploy, 1)
+		err := h.CheckDeployment(ns, deploy, 1)
 		gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to validate deploy/%s: %s", deploy, Err(err)))
 	}
 
-	err := testutil.ExerciseTestAppEndpoint("/api/list", emojivotoNs, h)
+	err := testutil.ExerciseTestAppEndpoint("/api/list", ns, h)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to exercise emojivoto endpoint: %s", Err(err)))
 }
 
-// TestEmojivotoApp installs and checks if emojivoto app is installed
-// called of the function must have `testdata/emojivoto.yml`
-func TestEmojivotoApp() {
-	ginkgo.By("Installing emojivoto")
+func installEmojivoto(ns, traceCollector string) {
+	ginkgo.By(fmt.Sprintf("Installing emojivoto in namespace %s", ns))
 	h, _ := GetHelperAndConfig()
-	resources, err := testutil.ReadFile("testdata/emojivoto.yml")
+	resources, err := RenderTemplate("testdata/emojivoto.yml", emojivotoValues{
+		TemplateValues: GetTemplateValues(),
+		Namespace:      ns,
+		TraceCollector: traceCollector,
+	})
+	gomega.Expect(err).Should(gomega.BeNil(), Err(err))
+
+	resources, err = ApplyImageConfig(resources)
 	gomega.Expect(err).Should(gomega.BeNil(), Err(err))
 
-	_, err = h.KubectlApply(resources, emojivotoNs)
+	// emojivoto is used by multiple specs, and is deleted once all of them complete
+	TrackSuiteNamespace(ns)
+
+	_, err = h.KubectlApply(resources, ns)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("could not apply emojivoto manifests to your cluster: %s", Err(err)))
-	checkSampleAppState()
+	checkSampleAppState(ns)
 }
 
-//TestEmojivotoInject installs and checks if emojivoto app is installed
-// called of the function must have `testdata/emojivoto.yml`
-func TestEmojivotoInject() {
+func injectEmojivoto(ns string, flags ...string) {
 	ginkgo.By("Injecting emojivoto")
-	h, _ := GetHelperAndConfig()
+	h, c := GetHelperAndConfig()
 
-	out, err := h.Kubectl("", "get", "deploy", "-n", emojivotoNs, "-o", "yaml")
+	out, err := h.Kubectl("", "get", "deploy", "-n", ns, "-o", "yaml")
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get manifests: %s", Err(err)))
 
-	out, stderr, err := h.PipeToLinkerdRun(out, "inject", "-")
+	cmd := append(append([]string{"inject"}, c.GetInjectImageFlags()...), flags...)
+	out, stderr, err := h.PipeToLinkerdRun(out, append(cmd, "-")...)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to inject: %s", stderr))
 
-	out, err = h.KubectlApply(out, emojivotoNs)
+	out, err = h.KubectlApply(out, ns)
 	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to apply injected resources: %s", Err(err)))
-	checkSampleAppState()
+	checkSampleAppState(ns)
 
-	for _, deploy := range emojivotoDeploys {
-		err := CheckProxyContainer(deploy, emojivotoNs)
+	for _, deploy := range EmojivotoDeploys {
+		err := CheckProxyContainer(deploy, ns)
 		gomega.Expect(err).Should(gomega.BeNil(), Err(err))
 	}
+
+	RunProxyCheck(h, ns)
+
+	emojivotoInstalled[ns] = true
+}
+
+// TestEmojivotoApp installs and checks if emojivoto app is installed in the given namespace
+// called of the function must have `testdata/emojivoto.yml`
+func TestEmojivotoApp(ns string) {
+	installEmojivoto(ns, "")
+}
+
+//TestEmojivotoInject injects and checks if emojivoto app is injected in the given namespace
+// called of the function must have `testdata/emojivoto.yml`
+func TestEmojivotoInject(ns string) {
+	injectEmojivoto(ns)
+}
+
+// TestTracedEmojivoto installs and injects emojivoto in the given namespace, with both
+// the app and its proxies sending spans to the given OpenCensus collector
+func TestTracedEmojivoto(ns, collector, collectorSvcAccount string) {
+	installEmojivoto(ns, collector)
+	injectEmojivoto(ns, "--trace-collector", collector, "--trace-collector-svc-account", collectorSvcAccount)
+}
+
+// EnsureEmojivoto installs and injects emojivoto in the given namespace, unless
+// it has already been done by the current Ginkgo node. When the specs run in
+// parallel, the Its of a Describe block may run on different nodes, hence Its
+// using emojivoto must call this instead of relying on a previous It
+func EnsureEmojivoto(ns string) {
+	if emojivotoInstalled[ns] {
+		return
+	}
+
+	TestEmojivotoApp(ns)
+	TestEmojivotoInject(ns)
 }
 
-// TestEmojivotoUninstall tests if emojivoto can be successfull uninstalled
-func TestEmojivotoUninstall() {
-	ginkgo.By("Uninstalling emojivoto")
+// TestEmojivotoUninstall tests if emojivoto can be successfull uninstalled from the given namespace
+func TestEmojivotoUninstall(ns string) {
+	if Parallel() && !emojivotoInstalled[ns] {
+		ginkgo.Skip(fmt.Sprintf("emojivoto was not installed in namespace %s by this node", ns))
+	}
+
+	ginkgo.By(fmt.Sprintf("Uninstalling emojivoto from namespace %s", ns))
 	h, _ := GetHelperAndConfig()
 
-	_, err := h.Kubectl("", "delete", "ns", emojivotoNs)
-	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("could not delete namespace %s: %s", emojivotoNs, Err(err)))
+	_, err := h.Kubectl("", "delete", "ns", ns)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("could not delete namespace %s: %s", ns, Err(err)))
+
+	delete(emojivotoInstalled, ns)
 }
 
 // CheckProxyContainer gets the pods from a deployment, and checks if the proxy container is present
@@ -290,6 +401,110 @@ func CheckProxyContainer(deployName, namespace string) error {
 	})
 }
 
+// GetProxyAdminPort returns the admin port of the proxy injected in the given pod
+func GetProxyAdminPort(pod corev1.Pod) (int, error) {
+	proxy := testutil.GetProxyContainer(pod.Spec.Containers)
+	if proxy == nil {
+		return 0, fmt.Errorf("could not find proxy container in pod/%s", pod.GetName())
+	}
+
+	for _, port := range proxy.Ports {
+		if port.Name == k8s.ProxyAdminPortName {
+			return int(port.ContainerPort), nil
+		}
+	}
+	return 0, fmt.Errorf("could not find port %s in pod/%s", k8s.ProxyAdminPortName, pod.GetName())
+}
+
+// GetProxyAdminURL port-forwards to the proxy admin port of the
+// given deployment and returns its URL
+func GetProxyAdminURL(namespace, deploy string) string {
+	h, _ := GetHelperAndConfig()
+
+	pods, err := h.GetPodsForDeployment(namespace, deploy)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get pods for deploy/%s: %s", deploy, Err(err)))
+	gomega.Expect(pods).ShouldNot(gomega.BeEmpty(), fmt.Sprintf("no pods found for deploy/%s", deploy))
+
+	port, err := GetProxyAdminPort(pods[0])
+	gomega.Expect(err).Should(gomega.BeNil(), Err(err))
+
+	url, err := h.URLFor(namespace, deploy, port)
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to port-forward to deploy/%s: %s", deploy, Err(err)))
+	return url
+}
+
+// GetDeployReplicas returns the number of replicas a deployment is scaled to
+func GetDeployReplicas(namespace, deploy string) string {
+	h, _ := GetHelperAndConfig()
+
+	out, err := h.Kubectl("", "-n", namespace, "get", "deploy", deploy, "-o", "jsonpath={.spec.replicas}")
+	gomega.Expect(err).Should(gomega.BeNil(), fmt.Sprintf("failed to get replicas for deploy/%s: %s", deploy, Err(err)))
+	return strings.TrimSpace(out)
+}
+
+// ScaleDeploy scales a deployment to the given number of replicas and waits for the rollout to complete
+func ScaleDeploy(namespace, deploy, replicas string) error {
+	h, _ := GetHelperAndConfig()
+
+	_, err := h.Kubectl("", "-n", namespace, "scale", "deploy", deploy, "--replicas="+replicas)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.Kubectl("", "-n", namespace, "rollout", "status", "--timeout=120s", "deploy/"+deploy)
+	return err
+}
+
+// apiResourceList is used for unmarshalling the API
+// discovery document of a group version
+type apiResourceList struct {
+	Resources []struct {
+		Name string `json:"name"`
+	} `json:"resources"`
+}
+
+// SupportsResource checks if the API server serves the
+// given resource (such as "ingresses") for a group version
+func SupportsResource(h *testutil.TestHelper, groupVersion, resource string) (bool, error) {
+	out, err := h.Kubectl("", "api-versions")
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch API versions: %s", err)
+	}
+
+	if indexOf(strings.Fields(out), groupVersion) == -1 {
+		return false, nil
+	}
+
+	path := "/apis/" + groupVersion
+	if groupVersion == "v1" {
+		path = "/api/v1"
+	}
+
+	out, err = h.Kubectl("", "get", "--raw", path)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch resources for %s: %s", groupVersion, err)
+	}
+
+	var list apiResourceList
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return false, fmt.Errorf("failed to unmarshal resources for %s: %s", groupVersion, err)
+	}
+
+	for _, r := range list.Resources {
+		if r.Name == resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SkipIfUnsupported is called within a Describe block to skip its specs when
+// the cluster lacks their prerequisites, with reason being reported as such.
+// reason is obtained from the Capabilities detected by RunPreflight
+func SkipIfUnsupported(reason string) bool {
+	return ShouldTestSkip(reason != "", fmt.Sprintf("Skipping as the cluster is not supported: %s", reason))
+}
+
 // ShouldTestSkip is called within a Describe block to determine if a test must be skipped
 func ShouldTestSkip(skip bool, message string) bool {
 	return ginkgo.BeforeEach(func() {